@@ -0,0 +1,62 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+var adminToken string
+var paused atomic.Bool
+
+func isAdmin(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	return ok && len(adminToken) != 0 && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
+}
+
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isAdmin(r) {
+			writeError(w, http.StatusUnauthorized, codeUnauthorized, "invalid admin token")
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+func pauseHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		paused.Store(true)
+
+		writeJSON(w, http.StatusOK, struct {
+			Success bool `json:"success"`
+			Paused  bool `json:"paused"`
+		}{
+			true,
+			true,
+		})
+		return
+	}
+
+	rejectMethod(w, r, http.MethodPost)
+}
+
+func resumeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		paused.Store(false)
+
+		writeJSON(w, http.StatusOK, struct {
+			Success bool `json:"success"`
+			Paused  bool `json:"paused"`
+		}{
+			true,
+			false,
+		})
+		return
+	}
+
+	rejectMethod(w, r, http.MethodPost)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsAdmin(t *testing.T) {
+	adminToken = "secret"
+	t.Cleanup(func() { adminToken = "" })
+
+	tests := []struct {
+		name   string
+		target string
+		header string
+		admin  bool
+	}{
+		{"bearer header", "/admin/pause", "Bearer secret", true},
+		{"wrong token", "/admin/pause", "Bearer wrong", false},
+		{"missing scheme", "/admin/pause", "secret", false},
+		{"query string", "/admin/pause?admin_token=secret", "", false},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, test.target, nil)
+		if len(test.header) != 0 {
+			r.Header.Set("Authorization", test.header)
+		}
+
+		if admin := isAdmin(r); admin != test.admin {
+			t.Errorf("%s: admin = %v, want %v", test.name, admin, test.admin)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const apiPrefix = "/v1"
+
+func versioned(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, apiPrefix+"/")
+}
+
+func applyVersionDefaults(r *http.Request, info *requestInfo) {
+	if versioned(r) && len(info.Format) == 0 && len(info.Transport) == 0 {
+		info.Format = "json"
+	}
+}
+
+func deprecated(successor string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+		next(w, r)
+	}
+}
+
+func handleAPI(mux *http.ServeMux, path string, handler http.HandlerFunc) {
+	handleLegacyAPI(mux, path, handler, handler)
+}
+
+func handleLegacyAPI(mux *http.ServeMux, path string, handler http.HandlerFunc, legacy http.HandlerFunc) {
+	mux.HandleFunc(apiPrefix+path, handler)
+	mux.HandleFunc(path, deprecated(apiPrefix+path, legacy))
+}
+
+func legacyLookupHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		owner, ok := matchAPIKey(r.Header.Get("Authorization"))
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if draining.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var body requestInfo
+		if json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(&body) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body.Owner = owner
+
+		if _, _, status, err := storeSubscription(&body); err != nil {
+			w.WriteHeader(status)
+			return
+		}
+
+		response, _ := json.Marshal(struct {
+			Success bool `json:"success"`
+		}{
+			true,
+		})
+
+		w.Header().Add("Content-Type", "application/json")
+		w.Write(response)
+		return
+	}
+
+	w.WriteHeader(http.StatusBadRequest)
+}
+
+func legacyWakeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		if draining.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var body wakeInfo
+		if json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(&body) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		identifier := wakeIdentifier(body.Identifier)
+		if len(identifier) == 0 || identifier == "0" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if _, _, err := wakeSubscriptions(identifier); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		fmt.Fprint(w, identifier)
+		return
+	}
+
+	w.WriteHeader(http.StatusBadRequest)
+}
+
+func apiNotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusNotFound, codeNotFound, "no such endpoint "+r.URL.Path)
+}
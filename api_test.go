@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLegacyLookup(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		reply  string
+	}{
+		{"stored", `{"Page":"https://steamcommunity.com/id/legacy/","Token":"token","Callback":"https://example.com/callback","Extra":true}`, http.StatusOK, `{"success":true}`},
+		{"missing token", `{"Page":"https://steamcommunity.com/id/legacy/","Callback":"https://example.com/callback"}`, http.StatusBadRequest, ""},
+		{"invalid json", `{"Page":`, http.StatusBadRequest, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setupTest(t)
+			allowPrivate = true
+			t.Cleanup(func() { allowPrivate = false })
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, "/lookup", strings.NewReader(test.body))
+			request.Header.Set("Content-Type", "text/plain")
+			legacyLookupHandler(recorder, request)
+
+			if recorder.Code != test.status || recorder.Body.String() != test.reply {
+				t.Fatalf("got %d %q, want %d %q", recorder.Code, recorder.Body.String(), test.status, test.reply)
+			}
+
+			if test.status == http.StatusOK && recorder.Header().Get("Content-Type") != "application/json" {
+				t.Fatalf("got content type %q", recorder.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestLegacyWake(t *testing.T) {
+	tests := []struct {
+		method string
+		body   string
+		status int
+		reply  string
+	}{
+		{http.MethodPost, `{"Identifier":76561197960287930}`, http.StatusOK, "76561197960287930"},
+		{http.MethodPost, `{"Identifier":0}`, http.StatusBadRequest, ""},
+		{http.MethodPost, `not json`, http.StatusBadRequest, ""},
+		{http.MethodGet, ``, http.StatusBadRequest, ""},
+	}
+
+	for _, test := range tests {
+		setupTest(t)
+
+		recorder := httptest.NewRecorder()
+		legacyWakeHandler(recorder, httptest.NewRequest(test.method, "/wake", strings.NewReader(test.body)))
+
+		if recorder.Code != test.status || recorder.Body.String() != test.reply {
+			t.Errorf("%s %s: got %d %q, want %d %q", test.method, test.body, recorder.Code, recorder.Body.String(), test.status, test.reply)
+		}
+	}
+}
+
+type failingStore struct {
+	*memoryStore
+	failPage string
+}
+
+func (s failingStore) Add(key string, info requestInfo) (bool, error) {
+	if strings.Contains(info.Page, s.failPage) {
+		return false, errors.New("disk full")
+	}
+
+	return s.memoryStore.Add(key, info)
+}
+
+func TestBatchLookupReportsStoreErrors(t *testing.T) {
+	setupTest(t)
+	allowPrivate = true
+	t.Cleanup(func() { allowPrivate = false })
+
+	requests := []requestInfo{
+		{Page: "https://steamcommunity.com/id/first/", Token: "token", Callback: "https://example.com/callback"},
+		{Page: "https://steamcommunity.com/id/broken/", Token: "token", Callback: "https://example.com/callback"},
+		{Page: "not a url", Token: "token", Callback: "https://example.com/callback"},
+		{Page: "https://steamcommunity.com/id/last/", Token: "token", Callback: "https://example.com/callback"},
+	}
+	subscriptions = failingStore{newMemoryStore(), "/broken"}
+
+	recorder := httptest.NewRecorder()
+	batchLookup(recorder, requests)
+
+	var body struct {
+		Success  bool
+		Accepted int
+		Results  []struct {
+			Success bool
+			Error   *apiError
+		}
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+
+	if recorder.Code != http.StatusOK || body.Success || body.Accepted != 2 || len(body.Results) != 4 {
+		t.Fatalf("got %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	for i, want := range []string{"", codeInternalError, codeInvalidURL, ""} {
+		result := body.Results[i]
+		if result.Success != (len(want) == 0) || (result.Error != nil && result.Error.Code != want) {
+			t.Errorf("result %d: got %+v, want %q", i, result, want)
+		}
+	}
+
+	if count, _ := subscriptions.Count(); count != 2 {
+		t.Fatalf("stored %d subscriptions", count)
+	}
+}
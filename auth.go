@@ -0,0 +1,65 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+var apiKeysPath string
+var apiKeys atomic.Pointer[[]string]
+
+func loadLines(path string) ([]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) != 0 && !strings.HasPrefix(line, "#") {
+			keys = append(keys, line)
+		}
+	}
+
+	return keys, nil
+}
+
+func keyFingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:4])
+}
+
+func matchAPIKey(header string) (string, bool) {
+	keys := apiKeys.Load()
+	if keys == nil {
+		return "", true
+	}
+
+	if !strings.HasPrefix(header, "Bearer ") {
+		return "", false
+	}
+
+	provided := []byte(strings.TrimPrefix(header, "Bearer "))
+	for _, key := range *keys {
+		if subtle.ConstantTimeCompare(provided, []byte(key)) == 1 {
+			return keyFingerprint(key), true
+		}
+	}
+
+	return "", false
+}
+
+func authorize(w http.ResponseWriter, r *http.Request) (string, bool) {
+	owner, ok := matchAPIKey(r.Header.Get("Authorization"))
+	if !ok {
+		writeError(w, http.StatusUnauthorized, codeUnauthorized, "invalid api key")
+	}
+
+	return owner, ok
+}
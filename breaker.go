@@ -0,0 +1,123 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerHalfOpen
+	breakerOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerHalfOpen:
+		return "half-open"
+	case breakerOpen:
+		return "open"
+	}
+
+	return "closed"
+}
+
+type breaker struct {
+	lock      sync.Mutex
+	name      string
+	threshold int
+	cooldown  time.Duration
+	state     breakerState
+	failures  int
+	openedAt  time.Time
+}
+
+var scrapeBreaker = &breaker{name: "scrape"}
+
+func (b *breaker) transition(state breakerState) {
+	if b.state == state {
+		return
+	}
+
+	slog.Info("Circuit breaker state changed", "breaker", b.name, "from", b.state.String(), "to", state.String(), "failures", b.failures)
+	b.state = state
+	b.openedAt = currentClock.Now()
+	subscriptionsRevision.Add(1)
+}
+
+func (b *breaker) allow() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if currentClock.Now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.transition(breakerHalfOpen)
+		return true
+	case breakerHalfOpen:
+		if currentClock.Now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.openedAt = currentClock.Now()
+		return true
+	}
+
+	return true
+}
+
+func (b *breaker) record(success bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if success {
+		b.failures = 0
+		b.transition(breakerClosed)
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || (b.threshold > 0 && b.failures >= b.threshold) {
+		b.transition(breakerOpen)
+	}
+}
+
+func (b *breaker) current() breakerState {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.state
+}
+
+var callbackBreakerFailures int
+var callbackBreakerCooldown time.Duration
+var callbackBreakers = make(map[string]*breaker)
+var callbackBreakersLock sync.Mutex
+
+func callbackBreaker(host string) *breaker {
+	callbackBreakersLock.Lock()
+	defer callbackBreakersLock.Unlock()
+
+	b, ok := callbackBreakers[host]
+	if !ok {
+		b = &breaker{name: "callback:" + host, threshold: callbackBreakerFailures, cooldown: callbackBreakerCooldown}
+		callbackBreakers[host] = b
+	}
+
+	return b
+}
+
+func callbackBreakerState(host string) breakerState {
+	callbackBreakersLock.Lock()
+	b, ok := callbackBreakers[host]
+	callbackBreakersLock.Unlock()
+
+	if !ok {
+		return breakerClosed
+	}
+
+	return b.current()
+}
@@ -0,0 +1,38 @@
+package main
+
+import "time"
+
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	NewTicker(d time.Duration) ticker
+}
+
+type ticker interface {
+	Chan() <-chan time.Time
+	Stop()
+}
+
+type systemClock struct{}
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+var currentClock clock = systemClock{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (systemClock) NewTicker(d time.Duration) ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+func (t systemTicker) Chan() <-chan time.Time {
+	return t.C
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeWaiter struct {
+	at      time.Time
+	channel chan time.Time
+}
+
+type fakeTicker struct {
+	clock    *fakeClock
+	next     time.Time
+	interval time.Duration
+	channel  chan time.Time
+}
+
+type fakeClock struct {
+	lock    sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+	tickers map[*fakeTicker]bool
+}
+
+func useFakeClock(t *testing.T) *fakeClock {
+	c := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), tickers: make(map[*fakeTicker]bool)}
+
+	previous := currentClock
+	currentClock = c
+	t.Cleanup(func() { currentClock = previous })
+
+	return c
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	channel := make(chan time.Time, 1)
+	if d <= 0 {
+		channel <- c.now
+		return channel
+	}
+
+	c.waiters = append(c.waiters, fakeWaiter{c.now.Add(d), channel})
+	return channel
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) ticker {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	t := &fakeTicker{clock: c, next: c.now.Add(d), interval: d, channel: make(chan time.Time, 1)}
+	c.tickers[t] = true
+	return t
+}
+
+func (t *fakeTicker) Chan() <-chan time.Time {
+	return t.channel
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.lock.Lock()
+	delete(t.clock.tickers, t)
+	t.clock.lock.Unlock()
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.now = c.now.Add(d)
+	pending := []fakeWaiter{}
+	for _, waiter := range c.waiters {
+		if waiter.at.After(c.now) {
+			pending = append(pending, waiter)
+			continue
+		}
+		waiter.channel <- c.now
+	}
+	c.waiters = pending
+
+	for t := range c.tickers {
+		for !t.next.After(c.now) {
+			select {
+			case t.channel <- c.now:
+			default:
+			}
+			t.next = t.next.Add(t.interval)
+		}
+	}
+}
+
+func (c *fakeClock) pending() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.waiters) + len(c.tickers)
+}
+
+func startUpdate(t *testing.T, source scraper) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		runUpdate(ctx, source, http.DefaultClient)
+		close(done)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func addSubscription(page string) string {
+	info := requestInfo{Page: page, Token: "token", Callback: "https://example.com/callback", Format: "json"}
+	key := subscriptionID(&info)
+	subscriptions.Put(key, info)
+	return key
+}
+
+func TestRunUpdateCadence(t *testing.T) {
+	setupTest(t)
+	clock := useFakeClock(t)
+
+	addSubscription("https://steamcommunity.com/id/cadence/")
+	source := &fakeScraper{responses: []*statusInfo{{StatusCode: 200, Visibility: "public"}}}
+	startUpdate(t, source)
+
+	for cycle := 1; cycle <= 3; cycle++ {
+		waitFor(t, func() bool { return clock.pending() == 1 && len(source.scraped()) == cycle })
+
+		clock.Advance(59 * time.Second)
+		if len(source.scraped()) != cycle {
+			t.Fatalf("cycle %d ran early", cycle+1)
+		}
+		clock.Advance(time.Second)
+	}
+}
+
+func TestRunUpdatePollDelay(t *testing.T) {
+	setupTest(t)
+	clock := useFakeClock(t)
+	currentConfig.Store(&config{CycleInterval: duration{time.Minute}, PollDelay: duration{5 * time.Second}})
+
+	addSubscription("https://steamcommunity.com/id/first/")
+	addSubscription("https://steamcommunity.com/id/second/")
+	source := &fakeScraper{responses: []*statusInfo{{StatusCode: 500}}}
+	startUpdate(t, source)
+
+	waitFor(t, func() bool { return clock.pending() == 1 && len(source.scraped()) == 1 })
+
+	clock.Advance(4 * time.Second)
+	if len(source.scraped()) != 1 {
+		t.Fatal("second profile scraped before the poll delay")
+	}
+
+	clock.Advance(time.Second)
+	waitFor(t, func() bool { return len(source.scraped()) == 2 })
+}
+
+func TestRunUpdateBackoff(t *testing.T) {
+	setupTest(t)
+	clock := useFakeClock(t)
+
+	key := addSubscription("https://steamcommunity.com/id/backoff/")
+	source := &fakeScraper{responses: []*statusInfo{{StatusCode: 500}}}
+	startUpdate(t, source)
+
+	expected := map[int]int{0: 1, 1: 1, 2: 2, 3: 2, 5: 2, 6: 3, 13: 3, 14: 4}
+	for minute := 0; minute <= 14; minute++ {
+		if want, ok := expected[minute]; ok {
+			waitFor(t, func() bool { return clock.pending() == 1 && len(source.scraped()) == want })
+		} else {
+			waitFor(t, func() bool { return clock.pending() == 1 })
+		}
+		clock.Advance(time.Minute)
+	}
+
+	subscriptionStatesLock.Lock()
+	state := subscriptionStates[key]
+	subscriptionStatesLock.Unlock()
+
+	if state.Failures != 4 || !state.BackoffUntil.Equal(clock.Now().Add(-time.Minute).Add(16*time.Minute)) {
+		t.Fatalf("got %d failures backing off until %v", state.Failures, state.BackoffUntil)
+	}
+}
+
+func TestTimestampsUseClock(t *testing.T) {
+	setupTest(t)
+	clock := useFakeClock(t)
+
+	response, err := fixtureScraper(t, "profile_offline.html").Scrape(context.Background(), "https://steamcommunity.com/id/fixture/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.LastOnline != "2023-12-31T20:48:00Z" {
+		t.Fatalf("last online %q", response.LastOnline)
+	}
+
+	info := requestInfo{Page: "https://steamcommunity.com/id/fixture", Callback: "https://example.com/callback", Format: "json"}
+	payload := callbackPayload{}
+	if err := json.Unmarshal([]byte(callbackJSON(&info, response)), &payload); err != nil {
+		t.Fatal(err)
+	}
+
+	if payload.DeliveredAt != "2024-01-01T00:00:00Z" || !payload.Timestamp.Equal(clock.Now()) {
+		t.Fatalf("delivered at %q with timestamp %v", payload.DeliveredAt, payload.Timestamp)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, info.Callback, nil)
+	signRequest(req, "secret", []byte("{}"))
+	if timestamp := req.Header.Get("X-Steam-Status-Timestamp"); timestamp != "1704067200" {
+		t.Fatalf("signed with timestamp %q", timestamp)
+	}
+}
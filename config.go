@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+type duration struct {
+	time.Duration
+}
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+
+	d.Duration = parsed
+	return nil
+}
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+type config struct {
+	PollDelay         duration
+	CycleInterval     duration
+	Jitter            float64
+	CallbackAllowlist []string
+	MaxSubscriptions  int
+}
+
+const minPollDelay = 500 * time.Millisecond
+const minCycleInterval = 5 * time.Second
+
+var configPath string
+var currentConfig atomic.Pointer[config]
+var pollDelay = 3000 * time.Millisecond
+var cycleInterval = 30000 * time.Millisecond
+var jitter = 0.1
+var maxSubscriptions int
+
+func defaultConfig() *config {
+	return &config{
+		PollDelay:        duration{pollDelay},
+		CycleInterval:    duration{cycleInterval},
+		Jitter:           jitter,
+		MaxSubscriptions: maxSubscriptions,
+	}
+}
+
+func (c *config) validate() error {
+	if c.PollDelay.Duration < minPollDelay {
+		return errors.New("poll delay must be at least " + minPollDelay.String())
+	}
+
+	if c.CycleInterval.Duration < minCycleInterval {
+		return errors.New("cycle interval must be at least " + minCycleInterval.String())
+	}
+
+	if c.Jitter < 0 || c.Jitter >= 1 {
+		return errors.New("jitter must be at least 0 and less than 1")
+	}
+
+	if c.MaxSubscriptions < 0 {
+		return errors.New("max subscriptions must not be negative")
+	}
+
+	for _, host := range c.CallbackAllowlist {
+		if len(strings.Trim(host, ".")) == 0 {
+			return errors.New("callback allowlist contains an empty host")
+		}
+	}
+
+	return nil
+}
+
+func loadConfig(path string) (*config, error) {
+	loaded := defaultConfig()
+
+	if len(path) != 0 {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(data, loaded); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := loaded.validate(); err != nil {
+		return nil, err
+	}
+
+	return loaded, nil
+}
+
+func reloadConfig() {
+	loaded, err := loadConfig(configPath)
+	if err != nil {
+		slog.Warn("Rejected config reload", "err", err)
+		return
+	}
+
+	currentConfig.Store(loaded)
+	slog.Info("Config reloaded")
+}
+
+func reloadAPIKeys() {
+	if len(apiKeysPath) == 0 {
+		return
+	}
+
+	keys, err := loadLines(apiKeysPath)
+	if err != nil {
+		slog.Warn("Rejected API key reload", "err", err)
+		return
+	}
+
+	apiKeys.Store(&keys)
+	slog.Info("API keys reloaded")
+}
+
+func watchConfig() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	for range signals {
+		reloadConfig()
+		reloadAPIKeys()
+	}
+}
+
+func callbackAllowed(info *requestInfo) bool {
+	allowlist := currentConfig.Load().CallbackAllowlist
+	if len(allowlist) == 0 || len(info.Transport) != 0 {
+		return true
+	}
+
+	parsed, err := url.Parse(info.Callback)
+	if err != nil {
+		return false
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(parsed.Hostname(), "."))
+
+	for _, allowed := range allowlist {
+		allowed = strings.ToLower(strings.Trim(allowed, "."))
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+
+	return false
+}
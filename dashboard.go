@@ -0,0 +1,110 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+type dashboardRow struct {
+	Page           string
+	Token          string
+	CallbackHost   string
+	GameName       string
+	IsPlaying      bool
+	HasStatus      bool
+	LastScrape     int
+	LastDelivery   string
+	LastDeliveryAt time.Time
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="30">
+<title>steam-status</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+th { background: #eee; }
+</style>
+</head>
+<body>
+<h1>Subscriptions ({{len .}})</h1>
+<table>
+<tr><th>Page</th><th>Token</th><th>Callback Host</th><th>Game</th><th>Playing</th><th>Last Scrape</th><th>Last Delivery</th></tr>
+{{range .}}<tr>
+<td>{{.Page}}</td>
+<td>{{.Token}}</td>
+<td>{{.CallbackHost}}</td>
+<td>{{if .HasStatus}}{{.GameName}}{{else}}-{{end}}</td>
+<td>{{if .HasStatus}}{{.IsPlaying}}{{else}}-{{end}}</td>
+<td>{{if .LastScrape}}{{.LastScrape}}{{else}}-{{end}}</td>
+<td>{{if .LastDelivery}}{{.LastDelivery}} at {{.LastDeliveryAt.Format "2006-01-02 15:04:05"}}{{else}}-{{end}}</td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+func dashboardHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		rejectMethod(w, r, http.MethodGet)
+		return
+	}
+
+	requests, err := subscriptions.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternalError, "failed to list subscriptions")
+		return
+	}
+
+	statuses, err := subscriptions.ListStatuses()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternalError, "failed to list subscriptions")
+		return
+	}
+
+	rows := []dashboardRow{}
+	keys := []string{}
+
+	for key, info := range requests {
+		host := ""
+		if parsed, err := url.Parse(info.Callback); err == nil {
+			host = parsed.Host
+		}
+
+		keys = append(keys, key)
+		rows = append(rows, dashboardRow{
+			Page:         info.Page,
+			Token:        maskToken(info.Token),
+			CallbackHost: host,
+		})
+
+		if entry, ok := statuses[key]; ok {
+			rows[len(rows)-1].HasStatus = true
+			rows[len(rows)-1].GameName = entry.Status.GameName
+			rows[len(rows)-1].IsPlaying = entry.Status.IsPlaying
+		}
+	}
+
+	subscriptionStatesLock.Lock()
+	for i, key := range keys {
+		state := subscriptionStates[key]
+		rows[i].LastScrape = state.LastScrape
+		rows[i].LastDelivery = state.LastDelivery
+		rows[i].LastDeliveryAt = state.LastDeliveryAt
+	}
+	subscriptionStatesLock.Unlock()
+
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].Page < rows[j].Page
+	})
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	dashboardTemplate.Execute(w, rows)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+type deadLetter struct {
+	info      requestInfo
+	response  *statusInfo
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"lastError"`
+	FailedAt  time.Time `json:"failedAt"`
+}
+
+var deadLetterSize int
+var deadLetterRetention time.Duration
+var deadLetters = make(map[string][]deadLetter)
+var deadLettersLock sync.Mutex
+var replays = make(chan deliveryJob, 100)
+
+func pruneDeadLetters(now time.Time) {
+	for key, entries := range deadLetters {
+		kept := entries[:0]
+		for _, entry := range entries {
+			if now.Sub(entry.FailedAt) < deadLetterRetention {
+				kept = append(kept, entry)
+			}
+		}
+
+		if len(kept) == 0 {
+			delete(deadLetters, key)
+		} else {
+			deadLetters[key] = kept
+		}
+	}
+}
+
+func recordDeadLetter(job deliveryJob, attempts int, reason string) {
+	if deadLetterSize <= 0 {
+		return
+	}
+
+	now := currentClock.Now()
+
+	deadLettersLock.Lock()
+	defer deadLettersLock.Unlock()
+
+	pruneDeadLetters(now)
+
+	entries := append(deadLetters[job.key], deadLetter{
+		info:      job.info,
+		response:  job.response,
+		Attempts:  attempts,
+		LastError: reason,
+		FailedAt:  now,
+	})
+	if len(entries) > deadLetterSize {
+		entries = entries[len(entries)-deadLetterSize:]
+	}
+	deadLetters[job.key] = entries
+}
+
+func returnDeadLetter(key string, entry deadLetter) {
+	deadLettersLock.Lock()
+	deadLetters[key] = append(deadLetters[key], entry)
+	deadLettersLock.Unlock()
+}
+
+func takeDeadLetters(key string) map[string][]deadLetter {
+	deadLettersLock.Lock()
+	defer deadLettersLock.Unlock()
+
+	pruneDeadLetters(currentClock.Now())
+
+	taken := make(map[string][]deadLetter)
+	for id, entries := range deadLetters {
+		if len(key) == 0 || id == key {
+			taken[id] = entries
+			delete(deadLetters, id)
+		}
+	}
+
+	return taken
+}
+
+func deadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		type deadLetterEntry struct {
+			ID       string       `json:"id"`
+			Page     string       `json:"page"`
+			Callback string       `json:"callback"`
+			Status   *statusInfo  `json:"status"`
+			Failures []deadLetter `json:"failures"`
+		}
+
+		deadLettersLock.Lock()
+		pruneDeadLetters(currentClock.Now())
+
+		entries := []deadLetterEntry{}
+		for key, failures := range deadLetters {
+			latest := failures[len(failures)-1]
+			entries = append(entries, deadLetterEntry{
+				ID:       key,
+				Page:     latest.info.Page,
+				Callback: latest.info.Callback,
+				Status:   latest.response,
+				Failures: append([]deadLetter{}, failures...),
+			})
+		}
+		deadLettersLock.Unlock()
+
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].ID < entries[j].ID
+		})
+
+		writeJSON(w, http.StatusOK, struct {
+			Success     bool              `json:"success"`
+			DeadLetters []deadLetterEntry `json:"deadLetters"`
+		}{
+			true,
+			entries,
+		})
+		return
+	}
+
+	rejectMethod(w, r, http.MethodGet)
+}
+
+func replayDeadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		var body struct {
+			ID string
+		}
+		if r.ContentLength != 0 {
+			if err := decodeBody(w, r, &body); err != nil {
+				writeDecodeError(w, err)
+				return
+			}
+		}
+
+		taken := takeDeadLetters(body.ID)
+		if len(body.ID) != 0 && len(taken) == 0 {
+			writeError(w, http.StatusNotFound, codeNotFound, "no dead letters for subscription")
+			return
+		}
+
+		replayed := 0
+		remaining := 0
+		skipped := 0
+		for key, entries := range taken {
+			current, ok, err := subscriptions.Get(key)
+			if err != nil {
+				slog.Error("Failed to read subscription for replay", "key", key, "err", err)
+			}
+
+			if err != nil || !ok {
+				skipped += len(entries)
+				continue
+			}
+
+			for _, entry := range entries {
+				info := entry.info
+				info.Token = current.Token
+				info.Callback = current.Callback
+
+				select {
+				case replays <- deliveryJob{key: key, info: info, response: entry.response}:
+					replayed++
+				default:
+					returnDeadLetter(key, entry)
+					remaining++
+				}
+			}
+		}
+
+		writeJSON(w, http.StatusOK, struct {
+			Success   bool `json:"success"`
+			Replayed  int  `json:"replayed"`
+			Skipped   int  `json:"skipped"`
+			Remaining int  `json:"remaining"`
+		}{
+			true,
+			replayed,
+			skipped,
+			remaining,
+		})
+		return
+	}
+
+	rejectMethod(w, r, http.MethodPost)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReplayKeepsOriginalSequence(t *testing.T) {
+	setupTest(t)
+	deadLetterSize = 10
+	deadLetterRetention = time.Hour
+	t.Cleanup(func() {
+		deadLetterSize = 0
+		deadLetters = make(map[string][]deadLetter)
+	})
+
+	lock := sync.Mutex{}
+	received := []callbackPayload{}
+	tokens := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload := callbackPayload{}
+		json.NewDecoder(r.Body).Decode(&payload)
+
+		lock.Lock()
+		received = append(received, payload)
+		tokens = append(tokens, r.Header.Get("API-Token"))
+		lock.Unlock()
+
+		io.WriteString(w, `{"success":true,"data":{"refresh":"next"}}`)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, waitDeliveries := startDeliveries(ctx, server.Client())
+	defer waitDeliveries()
+	defer cancel()
+
+	info := requestInfo{Page: "https://steamcommunity.com/id/replayed", Token: "current", Callback: server.URL, Format: "json", Sequence: 9}
+	key := subscriptionID(&info)
+	subscriptions.Put(key, info)
+
+	stale := info
+	stale.Token = "stale"
+	stale.Sequence = 3
+	recordDeadLetter(deliveryJob{key: key, info: stale, response: &statusInfo{StatusCode: 200, GameName: "Portal"}}, 1, "callback returned 500")
+
+	gone := requestInfo{Page: "https://steamcommunity.com/id/gone", Token: "token", Callback: server.URL, Format: "json"}
+	recordDeadLetter(deliveryJob{key: subscriptionID(&gone), info: gone, response: &statusInfo{StatusCode: 200}}, 1, "callback returned 500")
+
+	recorder := httptest.NewRecorder()
+	replayDeadLettersHandler(recorder, httptest.NewRequest(http.MethodPost, "/admin/deadletters/replay", strings.NewReader("")))
+
+	if recorder.Body.String() != `{"success":true,"replayed":1,"skipped":1,"remaining":0}` {
+		t.Fatalf("got reply %q", recorder.Body.String())
+	}
+
+	waitFor(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(received) == 1
+	})
+
+	if received[0].Sequence != 3 || received[0].GameName != "Portal" || tokens[0] != "current" {
+		t.Fatalf("replayed sequence %d for %q with token %q", received[0].Sequence, received[0].GameName, tokens[0])
+	}
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type deliveryJob struct {
+	key      string
+	info     requestInfo
+	response *statusInfo
+	expired  bool
+}
+
+type callbackAction int
+
+const (
+	callbackAccept callbackAction = iota
+	callbackRetry
+	callbackReject
+	callbackDrop
+)
+
+const maxCallbackBytes = 64 * 1024
+
+var deliveryWorkers int
+var callbackAttempts int
+var callbackRetryDelay time.Duration
+
+type callbackPayload struct {
+	Page              string          `json:"page"`
+	SteamID           string          `json:"steamId"`
+	ResolvedPage      string          `json:"resolvedPage"`
+	GameName          string          `json:"gameName"`
+	GameLink          string          `json:"gameLink"`
+	GameIcon          string          `json:"gameIcon"`
+	AppID             int             `json:"appId"`
+	IsPlaying         bool            `json:"isPlaying"`
+	Visibility        string          `json:"visibility"`
+	ProfileMissing    bool            `json:"profileMissing"`
+	PersonaName       string          `json:"personaName"`
+	Avatar            string          `json:"avatar"`
+	OnlineState       string          `json:"onlineState"`
+	Level             int             `json:"level"`
+	HoursOnRecord     float64         `json:"hoursOnRecord"`
+	HoursPastTwoWeeks float64         `json:"hoursPastTwoWeeks"`
+	LastOnline        string          `json:"lastOnline,omitempty"`
+	RecentGames       []gameEntry     `json:"recentGames"`
+	Timestamp         time.Time       `json:"timestamp"`
+	ObservedAt        string          `json:"observedAt"`
+	DeliveredAt       string          `json:"deliveredAt"`
+	Sequence          int64           `json:"sequence"`
+	Metadata          json.RawMessage `json:"metadata,omitempty"`
+}
+
+func callbackJSON(info *requestInfo, response *statusInfo) string {
+	now := currentClock.Now().UTC()
+	payload := callbackPayload{
+		Page:              info.callbackPage(),
+		SteamID:           info.SteamID,
+		ResolvedPage:      response.ResolvedPage,
+		GameName:          response.GameName,
+		GameLink:          response.GameLink,
+		GameIcon:          response.GameIcon,
+		AppID:             response.AppID,
+		IsPlaying:         response.IsPlaying,
+		Visibility:        response.Visibility,
+		ProfileMissing:    response.ProfileMissing,
+		PersonaName:       response.PersonaName,
+		Avatar:            response.AvatarURL,
+		OnlineState:       response.OnlineState,
+		Level:             response.Level,
+		HoursOnRecord:     response.HoursOnRecord,
+		HoursPastTwoWeeks: response.HoursPastTwoWeeks,
+		RecentGames:       response.RecentGames,
+		Timestamp:         now,
+		ObservedAt:        response.ObservedAt.Format(time.RFC3339),
+		DeliveredAt:       now.Format(time.RFC3339),
+		Sequence:          info.Sequence,
+		Metadata:          info.Metadata,
+	}
+
+	if response.OnlineState == "offline" {
+		payload.LastOnline = response.LastOnline
+	}
+
+	data, _ := json.Marshal(payload)
+	return string(data)
+}
+
+func callbackForm(info *requestInfo, response *statusInfo) string {
+	form := url.Values{}
+	form.Add("page", info.callbackPage())
+	form.Add("steamId", info.SteamID)
+	form.Add("resolvedPage", response.ResolvedPage)
+	form.Add("gameName", response.GameName)
+	form.Add("gameLink", response.GameLink)
+	form.Add("gameIcon", response.GameIcon)
+	form.Add("appId", strconv.Itoa(response.AppID))
+	form.Add("isPlaying", strconv.FormatBool(response.IsPlaying))
+	form.Add("visibility", response.Visibility)
+	form.Add("profileMissing", strconv.FormatBool(response.ProfileMissing))
+	form.Add("personaName", response.PersonaName)
+	form.Add("avatar", response.AvatarURL)
+	form.Add("onlineState", response.OnlineState)
+	form.Add("level", strconv.Itoa(response.Level))
+
+	form.Add("hoursOnRecord", strconv.FormatFloat(response.HoursOnRecord, 'f', -1, 64))
+	form.Add("hoursPastTwoWeeks", strconv.FormatFloat(response.HoursPastTwoWeeks, 'f', -1, 64))
+
+	if response.OnlineState == "offline" && len(response.LastOnline) != 0 {
+		form.Add("lastOnline", response.LastOnline)
+	}
+
+	recentGames, _ := json.Marshal(response.RecentGames)
+	form.Add("recentGames", string(recentGames))
+
+	form.Add("observedAt", response.ObservedAt.Format(time.RFC3339))
+	form.Add("deliveredAt", currentClock.Now().UTC().Format(time.RFC3339))
+	form.Add("sequence", strconv.FormatInt(info.Sequence, 10))
+
+	if len(info.Metadata) != 0 {
+		form.Add("metadata", string(info.Metadata))
+	}
+
+	return form.Encode()
+}
+
+func callbackBody(info *requestInfo, response *statusInfo) string {
+	switch info.Format {
+	case "json":
+		return callbackJSON(info, response)
+	case "discord":
+		return callbackDiscord(info, response)
+	case "slack":
+		return callbackSlack(info, response)
+	}
+
+	return callbackForm(info, response)
+}
+
+func newCallbackRequest(info *requestInfo, payload string) (*http.Request, error) {
+	method := info.Method
+	if len(method) == 0 {
+		method = http.MethodPost
+	}
+
+	req, err := http.NewRequest(method, info.Callback, strings.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range info.Headers {
+		req.Header.Set(name, value)
+	}
+
+	if !webhookFormat(info.Format) {
+		req.Header.Add("API-Route", "Steam")
+		req.Header.Add("API-Token", info.Token)
+	}
+	if info.Format == "json" || webhookFormat(info.Format) {
+		req.Header.Add("Content-Type", "application/json")
+	} else {
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	}
+	signRequest(req, info.Secret, []byte(payload))
+
+	return req, nil
+}
+
+func postCallback(client *http.Client, req *http.Request) (int, []byte, error) {
+	callback, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer func() {
+		io.Copy(io.Discard, io.LimitReader(callback.Body, maxCallbackBytes))
+		callback.Body.Close()
+	}()
+
+	body, err := ioutil.ReadAll(io.LimitReader(callback.Body, maxCallbackBytes))
+	return callback.StatusCode, body, err
+}
+
+func classifyCallback(code int, err error) callbackAction {
+	switch {
+	case err != nil || code >= 500:
+		return callbackRetry
+	case code == http.StatusUnauthorized || code == http.StatusForbidden || code == http.StatusNotFound || code == http.StatusGone:
+		return callbackDrop
+	case code >= 400:
+		return callbackReject
+	}
+
+	return callbackAccept
+}
+
+func deliverExpired(client *http.Client, job deliveryJob) {
+	form := url.Values{"page": {job.info.callbackPage()}, "steamId": {job.info.SteamID}, "expired": {"true"}}
+	if len(job.info.Metadata) != 0 {
+		form.Set("metadata", string(job.info.Metadata))
+	}
+
+	payload := form.Encode()
+	if job.info.Format == "json" {
+		data, _ := json.Marshal(struct {
+			Page      string          `json:"page"`
+			SteamID   string          `json:"steamId"`
+			Expired   bool            `json:"expired"`
+			Timestamp time.Time       `json:"timestamp"`
+			Metadata  json.RawMessage `json:"metadata,omitempty"`
+		}{job.info.callbackPage(), job.info.SteamID, true, currentClock.Now().UTC(), job.info.Metadata})
+		payload = string(data)
+	} else if webhookFormat(job.info.Format) {
+		payload = webhookExpired(&job.info)
+	}
+
+	req, err := newCallbackRequest(&job.info, payload)
+	if err != nil {
+		return
+	}
+
+	started := time.Now()
+	if _, _, err := postCallback(client, req); err != nil {
+		recordCallback("error", started)
+		return
+	}
+	recordCallback("expired", started)
+}
+
+func deliverStatus(ctx context.Context, client *http.Client, job deliveryJob) {
+	key := job.key
+	info := job.info
+
+	host := pageHost(info.Callback)
+	ctx, span := tracer.Start(ctx, "deliverStatus", trace.WithAttributes(attribute.String("steam.page", info.Page), attribute.String("callback.host", host)))
+	defer span.End()
+
+	hostBreaker := callbackBreaker(host)
+	if !hostBreaker.allow() {
+		recordCallback("skipped", time.Now())
+		recordDeliveryResult(key, "skipped")
+		recordDeadLetter(job, 0, "callback host circuit breaker is open")
+		return
+	}
+
+	delay := callbackRetryDelay
+	var started time.Time
+	var code int
+	var body []byte
+	var err error
+	var action callbackAction
+	attempt := 1
+
+	for ; ; attempt++ {
+		payload := callbackBody(&info, job.response)
+
+		var req *http.Request
+		req, err = newCallbackRequest(&info, payload)
+		if err != nil {
+			if err := subscriptions.DeleteStatus(key); err != nil {
+				slog.Error("Failed to invalidate status", "key", key, "err", err)
+			}
+			markStateDirty()
+			recordCallback("invalid", time.Now())
+			recordDeliveryResult(key, "invalid")
+			return
+		}
+
+		attemptCtx, attemptSpan := tracer.Start(ctx, "postCallback", trace.WithAttributes(attribute.Int("callback.attempt", attempt)))
+		injectTrace(attemptCtx, req)
+
+		started = time.Now()
+		code, body, err = postCallback(client, req)
+		attemptSpan.SetAttributes(attribute.Int("http.status_code", code))
+		if err != nil {
+			attemptSpan.SetStatus(codes.Error, err.Error())
+		}
+		attemptSpan.End()
+
+		action = classifyCallback(code, err)
+		hostBreaker.record(action != callbackRetry)
+
+		wait := delay
+		if info.Format == "discord" && code == http.StatusTooManyRequests {
+			action = callbackRetry
+			if retryAfter := discordRetryAfter(body); retryAfter > 0 {
+				wait = retryAfter
+			}
+		}
+
+		if action != callbackRetry {
+			break
+		}
+
+		if attempt >= callbackAttempts || hostBreaker.current() == breakerOpen || !sleepContext(ctx, wait) {
+			break
+		}
+		delay *= 2
+	}
+
+	switch {
+	case err != nil:
+		recordCallback("error", started)
+		span.SetStatus(codes.Error, err.Error())
+		recordDeadLetter(job, attempt, err.Error())
+		restore(key, &info, "error", err.Error())
+		return
+	case action == callbackDrop:
+		recordCallback("rejected", started)
+		recordDeliveryResult(key, "rejected")
+		slog.Warn("Removing subscription after callback rejected it", append(subscriptionAttrs(key, &info), "status", code)...)
+		dequeueRequest(key)
+		return
+	case action != callbackAccept:
+		recordCallback("rejected", started)
+		span.SetStatus(codes.Error, "callback returned "+strconv.Itoa(code))
+		recordDeadLetter(job, attempt, "callback returned "+strconv.Itoa(code))
+		restore(key, &info, "rejected", "callback returned "+strconv.Itoa(code))
+		return
+	}
+
+	refresh := ""
+	if info.Format == "slack" && strings.TrimSpace(string(body)) != "ok" {
+		recordCallback("rejected", started)
+		recordDeadLetter(job, attempt, "slack did not answer ok")
+		restore(key, &info, "rejected", "slack did not answer ok")
+		return
+	} else if !webhookFormat(info.Format) {
+		jsonBody := callbackInfo{}
+
+		if err := json.Unmarshal(body, &jsonBody); err != nil {
+			recordCallback("rejected", started)
+			recordDeadLetter(job, attempt, "invalid response: "+err.Error())
+			restore(key, &info, "rejected", "invalid response: "+err.Error())
+			return
+		}
+
+		if !jsonBody.Success || len(jsonBody.Data.Refresh) == 0 {
+			recordCallback("rejected", started)
+			recordDeadLetter(job, attempt, "response was not successful or had no refresh token")
+			restore(key, &info, "rejected", "response was not successful or had no refresh token")
+			return
+		}
+		refresh = jsonBody.Data.Refresh
+	}
+
+	recordCallback("success", started)
+	recordDeliveryResult(key, "success")
+	if err := subscriptions.ResetFailures(key); err != nil {
+		slog.Error("Failed to reset delivery failures", "key", key, "err", err)
+	}
+
+	if len(refresh) == 0 {
+		return
+	}
+
+	updateLock.Lock()
+	defer updateLock.Unlock()
+
+	current, ok, err := subscriptions.Get(key)
+	if err != nil || !ok || current.Token != info.Token {
+		return
+	}
+
+	current.Token = refresh
+	if err := subscriptions.Put(key, current); err != nil {
+		slog.Error("Failed to store refresh token", "key", key, "err", err)
+	}
+
+	markStateDirty()
+}
+
+func drainReplays(ctx context.Context, client *http.Client) {
+	for {
+		select {
+		case job := <-replays:
+			deliver(ctx, client, job)
+		default:
+			return
+		}
+	}
+}
+
+func startDeliveries(ctx context.Context, client *http.Client) (chan<- deliveryJob, func()) {
+	jobs := make(chan deliveryJob, 100)
+	workers := sync.WaitGroup{}
+
+	for i := 0; i < deliveryWorkers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for {
+				select {
+				case job, ok := <-jobs:
+					if !ok {
+						drainReplays(ctx, client)
+						return
+					}
+					deliver(ctx, client, job)
+				case job := <-replays:
+					deliver(ctx, client, job)
+				}
+			}
+		}()
+	}
+
+	return jobs, func() {
+		close(jobs)
+		workers.Wait()
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClassifyCallback(t *testing.T) {
+	tests := []struct {
+		code   int
+		err    error
+		action callbackAction
+	}{
+		{http.StatusOK, nil, callbackAccept},
+		{http.StatusNoContent, nil, callbackAccept},
+		{http.StatusUnauthorized, nil, callbackDrop},
+		{http.StatusForbidden, nil, callbackDrop},
+		{http.StatusNotFound, nil, callbackDrop},
+		{http.StatusGone, nil, callbackDrop},
+		{http.StatusBadRequest, nil, callbackReject},
+		{http.StatusConflict, nil, callbackReject},
+		{http.StatusTooManyRequests, nil, callbackReject},
+		{http.StatusInternalServerError, nil, callbackRetry},
+		{http.StatusBadGateway, nil, callbackRetry},
+		{http.StatusServiceUnavailable, nil, callbackRetry},
+		{0, errors.New("connection refused"), callbackRetry},
+	}
+
+	for _, test := range tests {
+		if action := classifyCallback(test.code, test.err); action != test.action {
+			t.Errorf("classifyCallback(%d, %v) = %v, want %v", test.code, test.err, action, test.action)
+		}
+	}
+}
+
+func TestDeliverStatusRecoversFromSlowCallback(t *testing.T) {
+	setupTest(t)
+	callbackAttempts = 1
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer slow.Close()
+
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"success":true,"data":{"refresh":"next"}}`)
+	}))
+	defer fast.Close()
+
+	client := newHTTPClient(http.DefaultTransport, 100*time.Millisecond)
+	slowInfo := requestInfo{Page: "https://steamcommunity.com/id/slow/", Token: "token", Callback: slow.URL, Format: "json"}
+	fastInfo := requestInfo{Page: "https://steamcommunity.com/id/fast/", Token: "token", Callback: fast.URL, Format: "json"}
+	subscriptions.Put("slow", slowInfo)
+	subscriptions.Put("fast", fastInfo)
+
+	started := time.Now()
+	deliverStatus(context.Background(), client, deliveryJob{key: "slow", info: slowInfo, response: &statusInfo{StatusCode: 200}})
+	deliverStatus(context.Background(), client, deliveryJob{key: "fast", info: fastInfo, response: &statusInfo{StatusCode: 200}})
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Fatalf("deliveries took %v", elapsed)
+	}
+
+	if _, ok, _ := subscriptions.Get("slow"); !ok {
+		t.Fatal("timed out subscription was removed")
+	}
+	if state := subscriptionStates["slow"]; state.LastDelivery != "error" {
+		t.Fatalf("slow delivery recorded %q", state.LastDelivery)
+	}
+	if current, _, _ := subscriptions.Get("fast"); current.Token != "next" {
+		t.Fatal("delivery after the timeout did not complete")
+	}
+}
+
+func TestPostCallbackReusesConnections(t *testing.T) {
+	padding := strings.Repeat(" ", maxCallbackBytes+maxCallbackBytes/2)
+	connections := atomic.Int32{}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"success":true,"data":{"refresh":"next"}}`+padding)
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	client := server.Client()
+	info := requestInfo{Callback: server.URL, Token: "token", Format: "json"}
+	goroutines := runtime.NumGoroutine()
+
+	for i := 0; i < 300; i++ {
+		req, err := newCallbackRequest(&info, "{}")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		code, _, err := postCallback(client, req)
+		if err != nil || code != http.StatusOK {
+			t.Fatalf("delivery %d: got %d, %v", i, code, err)
+		}
+	}
+
+	if count := connections.Load(); count > 2 {
+		t.Fatalf("opened %d connections for 300 deliveries", count)
+	}
+
+	if leaked := runtime.NumGoroutine() - goroutines; leaked > 5 {
+		t.Fatalf("leaked %d goroutines", leaked)
+	}
+}
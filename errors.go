@@ -0,0 +1,37 @@
+package main
+
+const (
+	codeInvalidJSON          = "invalid_json"
+	codeBodyTooLarge         = "body_too_large"
+	codeMissingField         = "missing_field"
+	codeInvalidURL           = "invalid_url"
+	codeInvalidField         = "invalid_field"
+	codeMethodNotAllowed     = "method_not_allowed"
+	codeUnsupportedMediaType = "unsupported_media_type"
+	codeUnauthorized         = "unauthorized"
+	codeForbidden            = "forbidden"
+	codeNotFound             = "not_found"
+	codeRateLimited          = "rate_limited"
+	codeQueueFull            = "queue_full"
+	codeUnavailable          = "unavailable"
+	codeUpstreamError        = "upstream_error"
+	codeInternalError        = "internal_error"
+)
+
+type apiError struct {
+	Code    string `json:"code"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+func newAPIError(code string, message string) *apiError {
+	return &apiError{Code: code, Message: message}
+}
+
+func newFieldError(code string, field string, message string) *apiError {
+	return &apiError{Code: code, Field: field, Message: message}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+var subscriptionsRevision atomic.Int64
+
+func hashETag(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+func subscriptionsETag() string {
+	now := currentClock.Now()
+	backoffs := 0
+
+	subscriptionStatesLock.Lock()
+	for _, state := range subscriptionStates {
+		if state.BackoffUntil.After(now) {
+			backoffs++
+		}
+	}
+	subscriptionStatesLock.Unlock()
+
+	return `"` + strconv.FormatInt(startTime.UnixNano(), 36) + "-" + strconv.FormatInt(subscriptionsRevision.Load(), 36) + "-" + strconv.Itoa(backoffs) + `"`
+}
+
+func checkETag(w http.ResponseWriter, r *http.Request, tag string) bool {
+	w.Header().Set("ETag", tag)
+
+	for _, candidate := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == tag {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+
+	return false
+}
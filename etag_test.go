@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSubscriptionsETag(t *testing.T) {
+	setupTest(t)
+	clock := useFakeClock(t)
+
+	key := addSubscription("https://steamcommunity.com/id/etag/")
+	recordScrapeResult(key, &statusInfo{StatusCode: 500}, time.Minute)
+	backingOff := subscriptionsETag()
+
+	if subscriptionsETag() != backingOff {
+		t.Fatal("etag changed without a change")
+	}
+
+	clock.Advance(2 * time.Minute)
+	expired := subscriptionsETag()
+	if expired == backingOff {
+		t.Fatal("etag kept after the backoff expired")
+	}
+
+	callbackBreaker("example.com").transition(breakerOpen)
+	t.Cleanup(func() { callbackBreaker("example.com").transition(breakerClosed) })
+	if subscriptionsETag() == expired {
+		t.Fatal("etag kept after the callback breaker opened")
+	}
+}
+
+func TestSubscriptionsETagChangesWhenStatusRemoved(t *testing.T) {
+	setupTest(t)
+
+	key := addSubscription("https://steamcommunity.com/id/etag/")
+	subscriptions.SetStatus(key, cacheEntry{Hash: "v2|etag", Status: statusInfo{StatusCode: 200}})
+	subscriptions.SetStatus("orphan", cacheEntry{Hash: "v2|orphan"})
+
+	before := subscriptionsETag()
+	if removed, err := sweepStatusCache(); err != nil || removed != 1 {
+		t.Fatalf("sweep removed %d, %v", removed, err)
+	}
+
+	swept := subscriptionsETag()
+	if swept == before {
+		t.Fatal("etag kept after the cache sweep removed a status")
+	}
+
+	info, _, _ := subscriptions.Get(key)
+	restore(key, &info, "error", "callback failed")
+	if subscriptionsETag() == swept {
+		t.Fatal("etag kept after a failed delivery invalidated the status")
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var eventsIdleTimeout time.Duration
+
+func writeEvent(w http.ResponseWriter, event hubEvent) error {
+	data, _ := json.Marshal(event.Message)
+
+	_, err := fmt.Fprintf(w, "id: %d\nevent: status\ndata: %s\n\n", event.ID, data)
+	return err
+}
+
+func eventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		rejectMethod(w, r, http.MethodGet)
+		return
+	}
+
+	key, status, apiErr := streamSubscription(r.URL.Query().Get("page"))
+	if apiErr != nil {
+		writeAPIError(w, status, apiErr)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, codeInternalError, "streaming is not supported")
+		return
+	}
+
+	lastID, err := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64)
+	if err != nil {
+		lastID = -1
+	}
+
+	listener := hub.subscribe(key)
+	defer hub.unsubscribe(key, listener)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	event, ok, err := latestEvent(key)
+	if err != nil {
+		slog.Error("Failed to read latest status", "key", key, "err", err)
+	}
+
+	if ok && event.ID != lastID {
+		if writeEvent(w, event) != nil {
+			return
+		}
+		lastID = event.ID
+	}
+	flusher.Flush()
+
+	idle := time.NewTimer(eventsIdleTimeout)
+	defer idle.Stop()
+
+	for {
+		select {
+		case event := <-listener:
+			if event.ID == lastID {
+				continue
+			}
+			lastID = event.ID
+
+			if writeEvent(w, event) != nil {
+				return
+			}
+			flusher.Flush()
+
+			if !idle.Stop() {
+				<-idle.C
+			}
+			idle.Reset(eventsIdleTimeout)
+		case <-idle.C:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/graph-gophers/graphql-go"
+)
+
+const graphqlSchema = `
+schema {
+	query: Query
+}
+
+type Query {
+	subscriptions(page: String): [Subscription!]!
+	subscription(id: ID!): Subscription
+}
+
+type Subscription {
+	id: ID!
+	page: String!
+	steamId: String
+	callbackHost: String!
+	transport: String!
+	expiresAt: String
+	lastUpdated: String
+	lastStatus: Status
+}
+
+type Status {
+	statusCode: Int!
+	isPlaying: Boolean!
+	gameName: String!
+	gameLink: String!
+	gameIcon: String!
+	appId: Int!
+	source: String!
+	visibility: String!
+	profileMissing: Boolean!
+	personaName: String!
+	avatarUrl: String!
+	onlineState: String!
+	level: Int!
+	recentGames: [Game!]!
+	lastOnline: String
+	resolvedPage: String!
+	attempts: Int!
+	error: String
+	observedAt: String
+	hoursOnRecord: Float!
+	hoursPastTwoWeeks: Float!
+}
+
+type Game {
+	name: String!
+	link: String!
+	icon: String!
+	hoursOnRecord: Float!
+	hoursPastTwoWeeks: Float!
+}
+`
+
+var graphqlRoot = graphql.MustParseSchema(graphqlSchema, &graphqlQuery{}, graphql.UseFieldResolvers(), graphql.MaxDepth(8))
+
+type graphqlQuery struct{}
+
+type graphqlSubscription struct {
+	key  string
+	info requestInfo
+}
+
+type graphqlStatus struct {
+	StatusCode        int32
+	IsPlaying         bool
+	GameName          string
+	GameLink          string
+	GameIcon          string
+	AppID             int32
+	Source            string
+	Visibility        string
+	ProfileMissing    bool
+	PersonaName       string
+	AvatarURL         string
+	OnlineState       string
+	Level             int32
+	RecentGames       []gameEntry
+	LastOnline        *string
+	ResolvedPage      string
+	Attempts          int32
+	Error             *string
+	ObservedAt        *string
+	HoursOnRecord     float64
+	HoursPastTwoWeeks float64
+}
+
+func optionalString(value string) *string {
+	if len(value) == 0 {
+		return nil
+	}
+
+	return &value
+}
+
+func optionalTime(value time.Time) *string {
+	if value.IsZero() {
+		return nil
+	}
+
+	return optionalString(value.UTC().Format(time.RFC3339))
+}
+
+func (q *graphqlQuery) Subscriptions(args struct{ Page *string }) ([]*graphqlSubscription, error) {
+	requests, err := subscriptions.List()
+	if err != nil {
+		return nil, err
+	}
+
+	page := ""
+	if args.Page != nil {
+		page = normalizePage(*args.Page)
+	}
+
+	results := []*graphqlSubscription{}
+	for key, info := range requests {
+		if len(page) != 0 && info.Page != page && info.Original != page {
+			continue
+		}
+		results = append(results, &graphqlSubscription{key, info})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].key < results[j].key
+	})
+
+	return results, nil
+}
+
+func (q *graphqlQuery) Subscription(args struct{ ID graphql.ID }) (*graphqlSubscription, error) {
+	info, ok, err := subscriptions.Get(string(args.ID))
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	return &graphqlSubscription{string(args.ID), info}, nil
+}
+
+func (s *graphqlSubscription) ID() graphql.ID {
+	return graphql.ID(s.key)
+}
+
+func (s *graphqlSubscription) Page() string {
+	return s.info.callbackPage()
+}
+
+func (s *graphqlSubscription) SteamID() *string {
+	return optionalString(s.info.SteamID)
+}
+
+func (s *graphqlSubscription) CallbackHost() string {
+	if len(s.info.Transport) != 0 {
+		return ""
+	}
+
+	return pageHost(s.info.Callback)
+}
+
+func (s *graphqlSubscription) Transport() string {
+	if len(s.info.Transport) == 0 {
+		return "http"
+	}
+
+	return s.info.Transport
+}
+
+func (s *graphqlSubscription) ExpiresAt() *string {
+	return optionalTime(s.info.ExpiresAt)
+}
+
+func (s *graphqlSubscription) LastUpdated() (*string, error) {
+	entry, ok, err := subscriptions.GetStatus(s.key)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	return optionalTime(entry.Updated), nil
+}
+
+func (s *graphqlSubscription) LastStatus() (*graphqlStatus, error) {
+	entry, ok, err := subscriptions.GetStatus(s.key)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	status := entry.Status
+	recentGames := status.RecentGames
+	if recentGames == nil {
+		recentGames = []gameEntry{}
+	}
+
+	return &graphqlStatus{
+		StatusCode:        int32(status.StatusCode),
+		IsPlaying:         status.IsPlaying,
+		GameName:          status.GameName,
+		GameLink:          status.GameLink,
+		GameIcon:          status.GameIcon,
+		AppID:             int32(status.AppID),
+		Source:            status.Source,
+		Visibility:        status.Visibility,
+		ProfileMissing:    status.ProfileMissing,
+		PersonaName:       status.PersonaName,
+		AvatarURL:         status.AvatarURL,
+		OnlineState:       status.OnlineState,
+		Level:             int32(status.Level),
+		RecentGames:       recentGames,
+		LastOnline:        optionalString(status.LastOnline),
+		ResolvedPage:      status.ResolvedPage,
+		Attempts:          int32(status.Attempts),
+		Error:             optionalString(status.Error),
+		ObservedAt:        optionalTime(status.ObservedAt),
+		HoursOnRecord:     status.HoursOnRecord,
+		HoursPastTwoWeeks: status.HoursPastTwoWeeks,
+	}, nil
+}
+
+func runGraphQL(ctx context.Context, w http.ResponseWriter, query string, operation string, variables map[string]interface{}) {
+	if len(query) == 0 {
+		writeError(w, http.StatusBadRequest, codeMissingField, "query is required")
+		return
+	}
+
+	response := graphqlRoot.Exec(ctx, query, operation, variables)
+
+	status := http.StatusOK
+	if response.Data == nil && len(response.Errors) != 0 {
+		status = http.StatusBadRequest
+	}
+
+	writeJSON(w, status, response)
+}
+
+func graphqlHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		variables := map[string]interface{}{}
+		if raw := r.URL.Query().Get("variables"); len(raw) != 0 {
+			if err := json.Unmarshal([]byte(raw), &variables); err != nil {
+				writeError(w, http.StatusBadRequest, codeInvalidJSON, "variables must be a JSON object")
+				return
+			}
+		}
+
+		runGraphQL(r.Context(), w, r.URL.Query().Get("query"), r.URL.Query().Get("operationName"), variables)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		if !requireJSON(w, r) {
+			return
+		}
+
+		var body struct {
+			Query         string
+			OperationName string
+			Variables     map[string]interface{}
+			Extensions    map[string]interface{}
+		}
+		if err := decodeBody(w, r, &body); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		runGraphQL(r.Context(), w, body.Query, body.OperationName, body.Variables)
+		return
+	}
+
+	rejectMethod(w, r, http.MethodGet, http.MethodPost)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	pb "github.com/TerrayTM/steam-status/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type grpcServer struct {
+	pb.UnimplementedSteamStatusServer
+}
+
+func toStatusProto(s *statusInfo) *pb.StatusInfo {
+	return &pb.StatusInfo{
+		StatusCode:     int32(s.StatusCode),
+		IsPlaying:      s.IsPlaying,
+		GameName:       s.GameName,
+		GameLink:       s.GameLink,
+		GameIcon:       s.GameIcon,
+		Source:         s.Source,
+		Visibility:     s.Visibility,
+		ProfileMissing: s.ProfileMissing,
+		PersonaName:    s.PersonaName,
+		AvatarUrl:      s.AvatarURL,
+		OnlineState:    s.OnlineState,
+		Level:          int32(s.Level),
+		AppId:          int32(s.AppID),
+	}
+}
+
+func grpcAuthorize(ctx context.Context) (string, error) {
+	header := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get("authorization")) != 0 {
+		header = md.Get("authorization")[0]
+	}
+
+	owner, ok := matchAPIKey(header)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "invalid api key")
+	}
+
+	return owner, nil
+}
+
+func (g *grpcServer) Subscribe(ctx context.Context, in *pb.RequestInfo) (*pb.SubscribeResponse, error) {
+	owner, err := grpcAuthorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	info := requestInfo{
+		Page:     in.GetPage(),
+		SteamID:  in.GetSteamId(),
+		Token:    in.GetToken(),
+		Callback: in.GetCallback(),
+		Secret:   in.GetSecret(),
+
+		NotifyNameChange: in.GetNotifyNameChange(),
+		IntervalSeconds:  int(in.GetIntervalSeconds()),
+		Format:           in.GetFormat(),
+		Method:           in.GetMethod(),
+		Headers:          in.GetHeaders(),
+		TtlSeconds:       int(in.GetTtlSeconds()),
+		NotifyExpired:    in.GetNotifyExpired(),
+		SendInitial:      in.GetSendInitial(),
+		NotifyOn:         in.GetNotifyOn(),
+		Transport:        in.GetTransport(),
+		Metadata:         json.RawMessage(in.GetMetadata()),
+
+		Owner: owner,
+	}
+
+	if err := validateRequest(&info); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Message)
+	}
+
+	if !callbackAllowed(&info) {
+		return nil, status.Error(codes.PermissionDenied, "callback host is not allowed")
+	}
+
+	if _, err := enqueueRequest(info); errors.Is(err, errQueueFull) {
+		return nil, status.Error(codes.ResourceExhausted, "subscription limit reached")
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, "failed to store subscription")
+	}
+
+	return &pb.SubscribeResponse{Success: true, Id: subscriptionID(&info)}, nil
+}
+
+func (g *grpcServer) Unsubscribe(ctx context.Context, in *pb.UnsubscribeRequest) (*pb.SubscribeResponse, error) {
+	if _, err := grpcAuthorize(ctx); err != nil {
+		return nil, err
+	}
+
+	info := requestInfo{Page: in.GetPage(), Callback: in.GetCallback()}
+	key, apiErr := subscriptionKey(in.GetId(), &info)
+	if apiErr != nil {
+		return nil, status.Error(codes.InvalidArgument, apiErr.Message)
+	}
+
+	found, err := dequeueRequest(key)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to remove subscription")
+	}
+
+	if !found {
+		return nil, status.Error(codes.NotFound, "subscription not found")
+	}
+
+	return &pb.SubscribeResponse{Success: true, Id: key}, nil
+}
+
+func (g *grpcServer) GetStatus(ctx context.Context, in *pb.StatusRequest) (*pb.StatusInfo, error) {
+	page, reason := canonicalPage(in.GetPage())
+	if len(reason) != 0 {
+		return nil, status.Error(codes.InvalidArgument, reason)
+	}
+
+	response, ok := onDemandStatus(ctx, page)
+	if !ok {
+		return nil, status.Error(codes.Unavailable, "steam is throttled or unavailable, retry later")
+	}
+
+	if response.StatusCode != 200 {
+		return nil, status.Errorf(codes.Unavailable, "failed to gather status: %d", response.StatusCode)
+	}
+
+	return toStatusProto(response), nil
+}
+
+func (g *grpcServer) WatchStatus(in *pb.StatusRequest, stream pb.SteamStatus_WatchStatusServer) error {
+	key, code, err := streamSubscription(in.GetPage())
+	switch code {
+	case http.StatusBadRequest:
+		return status.Error(codes.InvalidArgument, err.Message)
+	case http.StatusNotFound:
+		return status.Error(codes.NotFound, err.Message)
+	case http.StatusInternalServerError:
+		return status.Error(codes.Internal, err.Message)
+	}
+
+	listener := hub.subscribe(key)
+	defer hub.unsubscribe(key, listener)
+
+	for {
+		select {
+		case event := <-listener:
+			err := stream.Send(toStatusProto(&event.Status))
+			if err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
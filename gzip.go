@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var gzipMinBytes int
+
+var gzipWriters = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(nil)
+	},
+}
+
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	status  int
+	buffer  []byte
+	decided bool
+	gz      *gzip.Writer
+}
+
+func (g *gzipResponseWriter) WriteHeader(status int) {
+	if g.status == 0 {
+		g.status = status
+	}
+}
+
+func (g *gzipResponseWriter) Write(data []byte) (int, error) {
+	if g.decided {
+		if g.gz != nil {
+			return g.gz.Write(data)
+		}
+		return g.ResponseWriter.Write(data)
+	}
+
+	g.buffer = append(g.buffer, data...)
+	if len(g.buffer) >= gzipMinBytes {
+		if err := g.decide(len(g.ResponseWriter.Header().Get("Content-Encoding")) == 0); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(data), nil
+}
+
+func (g *gzipResponseWriter) decide(compress bool) error {
+	g.decided = true
+
+	if g.status == 0 {
+		g.status = http.StatusOK
+	}
+
+	if compress {
+		g.Header().Set("Content-Encoding", "gzip")
+		g.Header().Del("Content-Length")
+		g.gz = gzipWriters.Get().(*gzip.Writer)
+		g.gz.Reset(g.ResponseWriter)
+	}
+	g.ResponseWriter.WriteHeader(g.status)
+
+	buffered := g.buffer
+	g.buffer = nil
+	if len(buffered) == 0 {
+		return nil
+	}
+
+	_, err := g.Write(buffered)
+	return err
+}
+
+func (g *gzipResponseWriter) finish() {
+	if !g.decided {
+		g.decide(false)
+	}
+
+	if g.gz != nil {
+		g.gz.Close()
+		gzipWriters.Put(g.gz)
+		g.gz = nil
+	}
+}
+
+func (g *gzipResponseWriter) Flush() {
+	if !g.decided {
+		g.decide(false)
+	}
+
+	if g.gz != nil {
+		g.gz.Flush()
+	}
+
+	if flusher, ok := g.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (g *gzipResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := g.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+
+	g.decided = true
+	return hijacker.Hijack()
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		params := strings.Split(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(params[0]), "gzip") {
+			continue
+		}
+
+		for _, param := range params[1:] {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if q, err := strconv.ParseFloat(value, 64); err == nil && q == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+func gzipMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if gzipMinBytes <= 0 || r.Method == http.MethodHead || !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		writer := &gzipResponseWriter{ResponseWriter: w}
+		defer writer.finish()
+
+		next.ServeHTTP(writer, r)
+	})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGzipMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		accept     string
+		size       int
+		compressed bool
+	}{
+		{"large accepted", "gzip, deflate", 4096, true},
+		{"large not accepted", "", 4096, false},
+		{"large refused", "gzip;q=0", 4096, false},
+		{"small accepted", "gzip", 16, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			previous := gzipMinBytes
+			gzipMinBytes = 1024
+			t.Cleanup(func() { gzipMinBytes = previous })
+
+			payload := map[string]string{"data": strings.Repeat("a", test.size)}
+			handler := gzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, http.StatusOK, payload)
+			}))
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, "/subscriptions", nil)
+			if len(test.accept) != 0 {
+				request.Header.Set("Accept-Encoding", test.accept)
+			}
+			handler.ServeHTTP(recorder, request)
+
+			var body io.Reader = recorder.Body
+			encoding := recorder.Header().Get("Content-Encoding")
+			if test.compressed {
+				if encoding != "gzip" {
+					t.Fatalf("got content encoding %q, want gzip", encoding)
+				}
+
+				reader, err := gzip.NewReader(body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body = reader
+			} else if len(encoding) != 0 {
+				t.Fatalf("got content encoding %q, want none", encoding)
+			}
+
+			var decoded map[string]string
+			if err := json.NewDecoder(body).Decode(&decoded); err != nil {
+				t.Fatal(err)
+			}
+
+			if recorder.Code != http.StatusOK || decoded["data"] != payload["data"] {
+				t.Fatalf("got status %d and %d bytes of data", recorder.Code, len(decoded["data"]))
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+type transportOptions struct {
+	DialTimeout         time.Duration
+	TLSTimeout          time.Duration
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	Wrap                func(http.RoundTripper) http.RoundTripper
+}
+
+var maxIdleConnsPerHost int
+var idleConnTimeout time.Duration
+var transportHook func(http.RoundTripper) http.RoundTripper
+
+func newTransport(options transportOptions) *http.Transport {
+	transport := newSafeTransport(options.DialTimeout)
+
+	if options.TLSTimeout > 0 {
+		transport.TLSHandshakeTimeout = options.TLSTimeout
+	}
+
+	if options.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = options.MaxIdleConnsPerHost
+	}
+
+	if options.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = options.IdleConnTimeout
+	}
+
+	return transport
+}
+
+func (o transportOptions) wrap(transport http.RoundTripper) http.RoundTripper {
+	if o.Wrap == nil {
+		return transport
+	}
+
+	return o.Wrap(transport)
+}
+
+func newHTTPClient(transport http.RoundTripper, timeout time.Duration) *http.Client {
+	return &http.Client{Transport: transport, Timeout: timeout}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestTransportOptionsWrap(t *testing.T) {
+	wrapped := 0
+	options := transportOptions{Wrap: func(base http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			wrapped++
+			return &http.Response{StatusCode: http.StatusTeapot, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+		})
+	}}
+
+	client := newHTTPClient(options.wrap(newTransport(options)), time.Second)
+	response, err := client.Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	if wrapped != 1 || response.StatusCode != http.StatusTeapot {
+		t.Fatalf("wrapped %d times, status %d", wrapped, response.StatusCode)
+	}
+
+	if (transportOptions{}).wrap(http.DefaultTransport) != http.DefaultTransport {
+		t.Fatal("empty options should not wrap the transport")
+	}
+}
+
+func TestDeliverStatusUsesClient(t *testing.T) {
+	setupTest(t)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		io.WriteString(w, `{"success":true,"data":{"refresh":"next"}}`)
+	}))
+	defer server.Close()
+
+	info := requestInfo{Page: "https://steamcommunity.com/id/client/", Token: "first", Callback: server.URL, Format: "json"}
+	subscriptions.Put("client", info)
+
+	deliverStatus(context.Background(), server.Client(), deliveryJob{key: "client", info: info, response: &statusInfo{StatusCode: 200}})
+
+	current, _, _ := subscriptions.Get("client")
+	if requests != 1 || current.Token != "next" {
+		t.Fatalf("got %d requests and token %q", requests, current.Token)
+	}
+}
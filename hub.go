@@ -0,0 +1,133 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+)
+
+type statusMessage struct {
+	Page           string `json:"page"`
+	GameName       string `json:"gameName"`
+	GameLink       string `json:"gameLink"`
+	GameIcon       string `json:"gameIcon"`
+	IsPlaying      bool   `json:"isPlaying"`
+	Visibility     string `json:"visibility"`
+	ProfileMissing bool   `json:"profileMissing"`
+	PersonaName    string `json:"personaName"`
+	AvatarURL      string `json:"avatarUrl"`
+	OnlineState    string `json:"onlineState"`
+}
+
+type hubEvent struct {
+	ID      int64
+	Message statusMessage
+	Status  statusInfo
+}
+
+type statusHub struct {
+	lock      sync.Mutex
+	listeners map[string]map[chan hubEvent]bool
+}
+
+var hub = newStatusHub()
+
+func newStatusHub() *statusHub {
+	return &statusHub{
+		listeners: make(map[string]map[chan hubEvent]bool),
+	}
+}
+
+func newStatusMessage(page string, s *statusInfo) statusMessage {
+	return statusMessage{
+		Page:           page,
+		GameName:       s.GameName,
+		GameLink:       s.GameLink,
+		GameIcon:       s.GameIcon,
+		IsPlaying:      s.IsPlaying,
+		Visibility:     s.Visibility,
+		ProfileMissing: s.ProfileMissing,
+		PersonaName:    s.PersonaName,
+		AvatarURL:      s.AvatarURL,
+		OnlineState:    s.OnlineState,
+	}
+}
+
+func streamSubscription(raw string) (string, int, *apiError) {
+	if len(raw) == 0 {
+		return "", http.StatusBadRequest, newFieldError(codeMissingField, "page", "page is required")
+	}
+
+	page, reason := canonicalPage(raw)
+	if len(reason) != 0 {
+		return "", http.StatusBadRequest, newFieldError(codeInvalidURL, "page", reason)
+	}
+	page = resolvePage(page)
+
+	requests, err := subscriptions.List()
+	if err != nil {
+		return "", http.StatusInternalServerError, newAPIError(codeInternalError, "failed to list subscriptions")
+	}
+
+	match := ""
+	for key, info := range requests {
+		if (info.Page == page || info.Original == page) && (len(match) == 0 || key < match) {
+			match = key
+		}
+	}
+
+	if len(match) == 0 {
+		return "", http.StatusNotFound, newFieldError(codeNotFound, "page", "page has no subscription to stream")
+	}
+
+	return match, http.StatusOK, nil
+}
+
+func (h *statusHub) subscribe(key string) chan hubEvent {
+	listener := make(chan hubEvent, 8)
+
+	h.lock.Lock()
+	if _, ok := h.listeners[key]; !ok {
+		h.listeners[key] = make(map[chan hubEvent]bool)
+	}
+	h.listeners[key][listener] = true
+	h.lock.Unlock()
+
+	return listener
+}
+
+func (h *statusHub) unsubscribe(key string, listener chan hubEvent) {
+	h.lock.Lock()
+	delete(h.listeners[key], listener)
+	if len(h.listeners[key]) == 0 {
+		delete(h.listeners, key)
+	}
+	h.lock.Unlock()
+}
+
+func (h *statusHub) publish(key string, info *requestInfo, s *statusInfo) {
+	event := hubEvent{ID: info.Sequence, Message: newStatusMessage(info.Page, s), Status: *s}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for listener := range h.listeners[key] {
+		select {
+		case listener <- event:
+		default:
+		}
+	}
+}
+
+func latestEvent(key string) (hubEvent, bool, error) {
+	info, ok, err := subscriptions.Get(key)
+	if err != nil || !ok {
+		return hubEvent{}, false, err
+	}
+
+	entry, ok, err := subscriptions.GetStatus(key)
+	if err != nil || !ok {
+		return hubEvent{}, false, err
+	}
+
+	return hubEvent{ID: info.Sequence, Message: newStatusMessage(info.Page, &entry.Status), Status: entry.Status}, true, nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunCycleSkipsUnsubscribedHubPages(t *testing.T) {
+	setupTest(t)
+
+	listener := hub.subscribe("https://steamcommunity.com/id/watched/")
+	defer hub.unsubscribe("https://steamcommunity.com/id/watched/", listener)
+
+	source := &fakeScraper{responses: []*statusInfo{{StatusCode: 200}}}
+	if _, ok := runCycle(context.Background(), source, make(chan deliveryJob, 1)); !ok {
+		t.Fatal("cycle stopped")
+	}
+
+	if len(source.scraped()) != 0 {
+		t.Fatalf("scraped %v without a subscription", source.scraped())
+	}
+}
+
+func TestStreamSubscription(t *testing.T) {
+	setupTest(t)
+
+	moved := requestInfo{Page: "https://steamcommunity.com/profiles/76561197960287930", Original: "https://steamcommunity.com/id/vanity", Token: "token", Callback: "https://example.com/moved"}
+	subscriptions.Put(subscriptionID(&moved), moved)
+
+	tests := []struct {
+		page   string
+		key    string
+		status int
+	}{
+		{"https://steamcommunity.com/id/Vanity/", subscriptionID(&moved), http.StatusOK},
+		{"https://steamcommunity.com/profiles/76561197960287930/", subscriptionID(&moved), http.StatusOK},
+		{"https://steamcommunity.com/id/unwatched", "", http.StatusNotFound},
+		{"https://example.com/id/vanity", "", http.StatusBadRequest},
+		{"", "", http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		key, status, _ := streamSubscription(test.page)
+		if key != test.key || status != test.status {
+			t.Errorf("%q resolved to %q with %d, want %q with %d", test.page, key, status, test.key, test.status)
+		}
+	}
+}
+
+func TestEventsRejectsUnsubscribedPage(t *testing.T) {
+	setupTest(t)
+
+	recorder := httptest.NewRecorder()
+	eventsHandler(recorder, httptest.NewRequest(http.MethodGet, "/events?page=https://steamcommunity.com/id/unwatched", nil))
+
+	if recorder.Code != http.StatusNotFound || !strings.Contains(recorder.Body.String(), codeNotFound) {
+		t.Fatalf("got %d %s", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestProcessResultPublishesToVanityListener(t *testing.T) {
+	setupTest(t)
+
+	info := requestInfo{Page: "https://steamcommunity.com/profiles/76561197960287930", Original: "https://steamcommunity.com/id/vanity", Token: "token", Callback: "https://example.com/callback", Format: "json"}
+	subscriptions.Put(subscriptionID(&info), info)
+
+	key, _, err := streamSubscription("https://steamcommunity.com/id/vanity")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener := hub.subscribe(key)
+	defer hub.unsubscribe(key, listener)
+
+	processResult(context.Background(), make(chan deliveryJob, 1), currentConfig.Load(), info, &statusInfo{StatusCode: 200, IsPlaying: true, GameName: "Portal"})
+
+	select {
+	case event := <-listener:
+		if event.Message.GameName != "Portal" || event.ID != 1 {
+			t.Fatalf("got event %+v", event.Message)
+		}
+	default:
+		t.Fatal("listener on the vanity page got no event")
+	}
+}
+
+func TestHubForgetsListenersButNotStatus(t *testing.T) {
+	setupTest(t)
+
+	info := requestInfo{Page: "https://steamcommunity.com/id/leaving", Token: "token", Callback: "https://example.com/callback", Sequence: 5}
+	key := subscriptionID(&info)
+	subscriptions.Put(key, info)
+	subscriptions.SetStatus(key, cacheEntry{Hash: "v2|leaving", Status: statusInfo{StatusCode: 200, IsPlaying: true, GameName: "Portal"}})
+
+	listener := hub.subscribe(key)
+	hub.unsubscribe(key, listener)
+	if len(hub.listeners) != 0 {
+		t.Fatal("listeners kept after the last listener left")
+	}
+
+	event, ok, err := latestEvent(key)
+	if err != nil || !ok || event.ID != 5 || event.Message.GameName != "Portal" {
+		t.Fatalf("latest event %+v, %v, %v", event, ok, err)
+	}
+}
+
+func TestEventsInitialEvent(t *testing.T) {
+	tests := []struct {
+		lastID  string
+		initial bool
+	}{
+		{"", true},
+		{"4", true},
+		{"5", false},
+	}
+
+	for _, test := range tests {
+		setupTest(t)
+		previous := eventsIdleTimeout
+		eventsIdleTimeout = 20 * time.Millisecond
+		t.Cleanup(func() { eventsIdleTimeout = previous })
+
+		info := requestInfo{Page: "https://steamcommunity.com/id/reconnecting", Token: "token", Callback: "https://example.com/callback", Sequence: 5}
+		key := subscriptionID(&info)
+		subscriptions.Put(key, info)
+		subscriptions.SetStatus(key, cacheEntry{Hash: "v2|reconnecting", Status: statusInfo{StatusCode: 200, IsPlaying: true, GameName: "Portal"}})
+
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/events?page=https://steamcommunity.com/id/reconnecting", nil)
+		if len(test.lastID) != 0 {
+			request.Header.Set("Last-Event-ID", test.lastID)
+		}
+		eventsHandler(recorder, request)
+
+		if initial := strings.Contains(recorder.Body.String(), "id: 5\n"); initial != test.initial {
+			t.Errorf("Last-Event-ID %q sent initial event = %v, want %v", test.lastID, initial, test.initial)
+		}
+	}
+}
+
+func TestSocketOriginAllowed(t *testing.T) {
+	corsOrigins = []string{"https://dashboard.example.com"}
+	t.Cleanup(func() { corsOrigins = nil })
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"", true},
+		{"https://dashboard.example.com", true},
+		{"http://status.example.com", true},
+		{"https://evil.example.com", false},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "http://status.example.com/ws", nil)
+		if len(test.origin) != 0 {
+			r.Header.Set("Origin", test.origin)
+		}
+
+		if allowed := socketOriginAllowed(r); allowed != test.allowed {
+			t.Errorf("origin %q allowed = %v, want %v", test.origin, allowed, test.allowed)
+		}
+	}
+}
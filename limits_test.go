@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type endlessBody struct {
+	prefix string
+	read   int64
+}
+
+func (b *endlessBody) Read(p []byte) (int, error) {
+	n := copy(p, b.prefix)
+	b.prefix = b.prefix[n:]
+	for i := n; i < len(p); i++ {
+		p[i] = 'a'
+	}
+
+	b.read += int64(len(p))
+	return len(p), nil
+}
+
+func TestOversizedBodyRejected(t *testing.T) {
+	handlers := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"lookup", lookupHandler},
+		{"wake", wakeHandler},
+	}
+
+	for _, test := range handlers {
+		t.Run(test.name, func(t *testing.T) {
+			setupTest(t)
+
+			body := &endlessBody{prefix: `{"Page":"`}
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, "/"+test.name, io.NopCloser(body))
+			request.Header.Set("Content-Type", "application/json")
+			test.handler(recorder, request)
+
+			if recorder.Code != http.StatusRequestEntityTooLarge {
+				t.Fatalf("got status %d, want %d", recorder.Code, http.StatusRequestEntityTooLarge)
+			}
+
+			var reply struct {
+				Success bool
+				Error   apiError
+			}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &reply); err != nil {
+				t.Fatal(err)
+			}
+
+			if reply.Success || reply.Error.Code != codeBodyTooLarge {
+				t.Fatalf("got reply %s", recorder.Body.String())
+			}
+
+			if body.read > maxBodyBytes*2 {
+				t.Fatalf("read %d bytes with a limit of %d", body.read, maxBodyBytes)
+			}
+		})
+	}
+}
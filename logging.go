@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+)
+
+func setupLogging(level string, format string) error {
+	var parsed slog.Level
+	if err := parsed.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q", level)
+	}
+
+	options := &slog.HandlerOptions{Level: parsed}
+
+	switch strings.ToLower(format) {
+	case "text":
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, options)))
+	case "json":
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, options)))
+	default:
+		return fmt.Errorf("invalid log format %q", format)
+	}
+
+	return nil
+}
+
+func pageHost(page string) string {
+	if parsed, err := url.Parse(page); err == nil {
+		return parsed.Host
+	}
+
+	return ""
+}
+
+func subscriptionAttrs(key string, info *requestInfo) []any {
+	return []any{"key", key, "page", info.Page, "callbackHost", pageHost(info.Callback)}
+}
@@ -1,36 +1,134 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"log"
+	"log/slog"
+	"math/rand"
+	"net"
 	"net/http"
 	"net/url"
+	"os"
+	"os/signal"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	pb "github.com/TerrayTM/steam-status/proto"
 	"github.com/gocolly/colly"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
 )
 
+const minIntervalSeconds = 30
+const minTTLSeconds = 60
+const maxBackoff = time.Hour
+const maxHeaders = 10
+const maxHeaderLength = 1024
+const maxMetadataBytes = 2048
+
+var headerNamePattern = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+var reservedHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Content-Type":      true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+	"Keep-Alive":        true,
+	"Te":                true,
+	"Trailer":           true,
+	"Upgrade":           true,
+	"Api-Route":         true,
+	"Api-Token":         true,
+}
+
+var notifyTransitions = map[string]bool{
+	"start":  true,
+	"stop":   true,
+	"change": true,
+}
+
 type requestInfo struct {
 	Page     string
+	SteamID  string
 	Token    string
 	Callback string
+	Secret   string
+
+	NotifyNameChange bool
+	IntervalSeconds  int
+	Format           string
+	Method           string
+	Headers          map[string]string
+	TtlSeconds       int
+	NotifyExpired    bool
+	SendInitial      bool
+	NotifyOn         []string
+	Transport        string
+	Metadata         json.RawMessage
+
+	Original  string    `json:"-"`
+	Owner     string    `json:"-"`
+	ExpiresAt time.Time `json:"-"`
+	Sequence  int64     `json:"-"`
+}
+
+type batchRequestInfo struct {
+	requestInfo
+	Requests []requestInfo
+}
+
+type subscriptionRef struct {
+	requestInfo
+	ID string
 }
 
 type wakeInfo struct {
-	Identifier int
+	Identifier json.RawMessage
 }
 
 type statusInfo struct {
-	StatusCode int
-	IsPlaying  bool
-	GameName   string
-	GameLink   string
-	GameIcon   string
+	StatusCode     int         `json:"statusCode"`
+	IsPlaying      bool        `json:"isPlaying"`
+	GameName       string      `json:"gameName"`
+	GameLink       string      `json:"gameLink"`
+	GameIcon       string      `json:"gameIcon"`
+	AppID          int         `json:"appId"`
+	Source         string      `json:"source"`
+	Visibility     string      `json:"visibility"`
+	ProfileMissing bool        `json:"profileMissing"`
+	PersonaName    string      `json:"personaName"`
+	AvatarURL      string      `json:"avatarUrl"`
+	OnlineState    string      `json:"onlineState"`
+	Level          int         `json:"level"`
+	RecentGames    []gameEntry `json:"recentGames"`
+	LastOnline     string      `json:"lastOnline,omitempty"`
+	ResolvedPage   string      `json:"resolvedPage"`
+	Attempts       int         `json:"attempts"`
+	Error          string      `json:"error,omitempty"`
+	ObservedAt     time.Time   `json:"observedAt"`
+
+	PermanentRedirect bool          `json:"-"`
+	RetryAfter        time.Duration `json:"-"`
+
+	HoursOnRecord     float64 `json:"hoursOnRecord"`
+	HoursPastTwoWeeks float64 `json:"hoursPastTwoWeeks"`
 }
 
 type callbackData struct {
@@ -42,209 +140,2140 @@ type callbackInfo struct {
 	Data    callbackData
 }
 
-var client http.Client
-var statusCache map[string]string
-var requestQueue map[string]requestInfo
-var requestQueueLock sync.Mutex
+type gameEntry struct {
+	Name              string  `json:"name"`
+	Link              string  `json:"link"`
+	Icon              string  `json:"icon"`
+	HoursOnRecord     float64 `json:"hoursOnRecord"`
+	HoursPastTwoWeeks float64 `json:"hoursPastTwoWeeks"`
+}
+
+type cacheEntry struct {
+	Hash    string
+	Status  statusInfo
+	Updated time.Time
+}
+
+type subscriptionState struct {
+	LastScrape     int
+	MissingCount   int
+	LastDelivery   string
+	LastDeliveryAt time.Time
+	NextPollAt     time.Time
+	Failures       int
+	BackoffUntil   time.Time
+}
+
+type cycleStats struct {
+	LastCycle     time.Time
+	Scrapes       int
+	FailedScrapes int
+}
+
+var callbackClient *http.Client
+var steamClient *http.Client
+var subscriptionStates map[string]subscriptionState
+var subscriptionStatesLock sync.Mutex
+var statusTimeout time.Duration
+var requireHTTPSCallbacks bool
+var missingThreshold int
+var scrapeAttempts int
+var scrapeRetryDelay time.Duration
+var scrapeTimeout time.Duration
+var deliveryFailureThreshold int
+var scrapeMaxBytes int
+var stats cycleStats
+var statsLock sync.Mutex
+var startTime time.Time
+var draining atomic.Bool
+var updateLock sync.Mutex
+var maxBodyBytes int64
+var errQueueFull = errors.New("subscription limit reached")
+
+func (r *requestInfo) callbackPage() string {
+	if len(r.Original) != 0 {
+		return r.Original
+	}
+
+	return r.Page
+}
+
+func subscriptionID(r *requestInfo) string {
+	sum := sha256.Sum256([]byte(r.callbackPage() + "\x00" + r.Callback))
+	return hex.EncodeToString(sum[:16])
+}
+
+func subscriptionKey(id string, r *requestInfo) (string, *apiError) {
+	if len(id) != 0 {
+		return id, nil
+	}
+
+	if transport := strings.ToLower(r.Transport); len(r.Callback) == 0 && len(transport) != 0 && transport != "http" {
+		r.Callback = transport + ":"
+	}
+
+	if len(r.Page) == 0 || len(r.Callback) == 0 {
+		return "", newFieldError(codeMissingField, "id", "id or page and callback are required")
+	}
+
+	r.Page = normalizePage(r.Page)
+	return subscriptionID(r), nil
+}
+
+const statusHashVersion = "v2|"
 
-func hashInfo(r *requestInfo) string {
-	return r.Page + "|" + r.Callback
+func statusDump(s *statusInfo) string {
+	return strconv.Itoa(s.AppID) + "|" + s.GameName + "|" + strconv.FormatBool(s.IsPlaying) + "|" + s.Visibility + "|" + strconv.FormatBool(s.ProfileMissing) + "|" + s.OnlineState
 }
 
 func hashStatus(s *statusInfo, r *requestInfo) string {
-	return s.GameLink + "|" + strconv.FormatBool(s.IsPlaying) + "|" + r.Callback
+	if r.NotifyNameChange {
+		return statusHashVersion + statusDump(s) + "|" + s.PersonaName + "|" + r.Callback
+	}
+
+	return statusHashVersion + statusDump(s) + "|" + r.Callback
 }
 
-func indexHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+func nextSequence(key string, info requestInfo) (requestInfo, error) {
+	updateLock.Lock()
+	defer updateLock.Unlock()
 
-	requestQueueLock.Lock()
-	fmt.Fprintf(w, "Server is online! Currently has "+strconv.Itoa(len(requestQueue))+" entries in request queue and "+strconv.Itoa(len(statusCache))+" entries in cache!")
-	requestQueueLock.Unlock()
+	current, ok, err := subscriptions.Get(key)
+	if err != nil || !ok {
+		return info, err
+	}
+
+	current.Sequence++
+	return current, subscriptions.Put(key, current)
 }
 
-func wakeHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method == http.MethodPost {
-		decoder := json.NewDecoder(r.Body)
+func previousStatus(key string, info *requestInfo) (cacheEntry, bool, error) {
+	entry, ok, err := subscriptions.GetStatus(key)
+	if err != nil || !ok || strings.HasPrefix(entry.Hash, statusHashVersion) {
+		return entry, ok, err
+	}
 
-		var body wakeInfo
-		err := decoder.Decode(&body)
-		if err != nil || body.Identifier == 0 {
-			w.WriteHeader(http.StatusBadRequest)
-			return
+	entry.Hash = hashStatus(&entry.Status, info)
+	markStateDirty()
+	return entry, ok, subscriptions.SetStatus(key, entry)
+}
+
+func statusTransition(previous *statusInfo, current *statusInfo) string {
+	switch {
+	case !previous.IsPlaying && current.IsPlaying:
+		return "start"
+	case previous.IsPlaying && !current.IsPlaying:
+		return "stop"
+	}
+
+	return "change"
+}
+
+func wantsTransition(info *requestInfo, transition string) bool {
+	if len(info.NotifyOn) == 0 {
+		return true
+	}
+
+	for _, wanted := range info.NotifyOn {
+		if wanted == transition {
+			return true
+		}
+	}
+
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	response, _ := json.Marshal(v)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(response)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err *apiError) {
+	writeJSON(w, status, struct {
+		Success bool      `json:"success"`
+		Error   *apiError `json:"error"`
+	}{
+		false,
+		err,
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	writeAPIError(w, status, newAPIError(code, message))
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) *apiError {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		var sizeErr *http.MaxBytesError
+		if errors.As(err, &sizeErr) {
+			return newAPIError(codeBodyTooLarge, "request body must not exceed "+strconv.FormatInt(sizeErr.Limit, 10)+" bytes")
+		}
+
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return newFieldError(codeInvalidField, typeErr.Field, typeErr.Field+" must be a "+typeErr.Type.String())
+		}
+
+		if field := strings.TrimPrefix(err.Error(), "json: unknown field "); field != err.Error() {
+			field, _ = strconv.Unquote(field)
+			return newFieldError(codeInvalidField, field, "unknown field "+field)
 		}
 
-		fmt.Fprintf(w, strconv.Itoa(body.Identifier))
+		return newAPIError(codeInvalidJSON, "request body is not valid json: "+err.Error())
+	}
+
+	if decoder.Decode(&struct{}{}) != io.EOF {
+		return newAPIError(codeInvalidJSON, "request body has data after the json object")
+	}
+
+	return nil
+}
+
+func writeDecodeError(w http.ResponseWriter, err *apiError) {
+	if err.Code == codeBodyTooLarge {
+		writeAPIError(w, http.StatusRequestEntityTooLarge, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusBadRequest)
+	writeAPIError(w, http.StatusBadRequest, err)
 }
 
-func lookupHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method == http.MethodPost {
-		decoder := json.NewDecoder(r.Body)
+func rejectMethod(w http.ResponseWriter, r *http.Request, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(append(allowed, http.MethodOptions), ", "))
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, r.Method+" is not allowed")
+}
+
+func maskToken(token string) string {
+	if len(token) <= 4 {
+		return strings.Repeat("*", len(token))
+	}
+
+	return strings.Repeat("*", len(token)-4) + token[len(token)-4:]
+}
+
+func newAPIMux() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/", indexHandler)
+	mux.HandleFunc(apiPrefix+"/", apiNotFoundHandler)
+	handleLegacyAPI(mux, "/wake", rateLimit(wakeHandler), rateLimit(legacyWakeHandler))
+	handleLegacyAPI(mux, "/lookup", rateLimit(lookupHandler), rateLimit(legacyLookupHandler))
+	handleAPI(mux, "/unsubscribe", unsubscribeHandler)
+	handleAPI(mux, "/renew", renewHandler)
+	handleAPI(mux, "/status", rateLimit(statusHandler))
+	handleAPI(mux, "/status/cached", cachedStatusHandler)
+	handleAPI(mux, "/subscriptions", subscriptionsHandler)
+	mux.HandleFunc("/graphql", graphqlHandler)
+	mux.HandleFunc("/healthz", healthHandler)
+	mux.HandleFunc("/version", versionHandler)
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/ws", rateLimit(socketHandler))
+	mux.HandleFunc("/events", rateLimit(eventsHandler))
+	mux.HandleFunc("/admin/pause", requireAdmin(pauseHandler))
+	mux.HandleFunc("/admin/resume", requireAdmin(resumeHandler))
+	mux.HandleFunc("/admin/deadletters", requireAdmin(deadLettersHandler))
+	mux.HandleFunc("/admin/deadletters/replay", requireAdmin(replayDeadLettersHandler))
+	mux.HandleFunc("/dashboard", requireAdmin(dashboardHandler))
+
+	return mux
+}
+
+func indexHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		apiNotFoundHandler(w, r)
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		rejectMethod(w, r, http.MethodGet, http.MethodHead)
+		return
+	}
+
+	queueSize, cacheSize := storeSizes()
+
+	writeJSON(w, http.StatusOK, struct {
+		Success   bool   `json:"success"`
+		Message   string `json:"message"`
+		Version   string `json:"version"`
+		QueueSize int    `json:"queueSize"`
+		CacheSize int    `json:"cacheSize"`
+	}{
+		true,
+		"Server " + version + " is online! Currently has " + strconv.Itoa(queueSize) + " entries in request queue and " + strconv.Itoa(cacheSize) + " entries in cache!",
+		version,
+		queueSize,
+		cacheSize,
+	})
+}
+
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		rejectMethod(w, r, http.MethodGet, http.MethodHead)
+		return
+	}
+
+	queueSize, cacheSize := storeSizes()
+
+	statsLock.Lock()
+	current := stats
+	statsLock.Unlock()
+
+	settings := currentConfig.Load()
+
+	var lastCycle *time.Time
+	if !current.LastCycle.IsZero() {
+		lastCycle = &current.LastCycle
+	}
+
+	var steam *probeResult
+	if result, ok := currentProbe(); ok {
+		steam = &result
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Success       bool         `json:"success"`
+		QueueSize     int          `json:"queueSize"`
+		CacheSize     int          `json:"cacheSize"`
+		LastCycle     *time.Time   `json:"lastCycle"`
+		Scrapes       int          `json:"scrapes"`
+		FailedScrapes int          `json:"failedScrapes"`
+		Uptime        float64      `json:"uptime"`
+		Paused        bool         `json:"paused"`
+		Throttled     bool         `json:"throttled"`
+		PollDelay     duration     `json:"pollDelay"`
+		CycleInterval duration     `json:"cycleInterval"`
+		Steam         *probeResult `json:"steam"`
+		ScrapeBreaker string       `json:"scrapeBreaker"`
+	}{
+		true,
+		queueSize,
+		cacheSize,
+		lastCycle,
+		current.Scrapes,
+		current.FailedScrapes,
+		time.Since(startTime).Seconds(),
+		paused.Load(),
+		throttled(),
+		settings.PollDelay,
+		settings.CycleInterval,
+		steam,
+		scrapeBreaker.current().String(),
+	})
+}
+
+func rejectQueueFull(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(currentConfig.Load().CycleInterval.Seconds())))
+	writeError(w, http.StatusTooManyRequests, codeQueueFull, "subscription limit reached")
+}
+
+func rejectDraining(w http.ResponseWriter) bool {
+	if !draining.Load() {
+		return false
+	}
+
+	writeError(w, http.StatusServiceUnavailable, codeUnavailable, "server is shutting down")
+	return true
+}
+
+func wakeHandler(w http.ResponseWriter, r *http.Request) {
+	if rejectDraining(w) {
+		return
+	}
 
-		var body requestInfo
-		err := decoder.Decode(&body)
-		if err != nil || len(body.Page) == 0 || len(body.Token) == 0 || len(body.Callback) == 0 {
-			w.WriteHeader(http.StatusBadRequest)
+	if r.Method == http.MethodPost {
+		if !requireJSON(w, r) {
 			return
 		}
 
-		_, errOne := url.ParseRequestURI(body.Page)
-		_, errTwo := url.ParseRequestURI(body.Callback)
-		if errOne != nil || errTwo != nil {
-			w.WriteHeader(http.StatusBadRequest)
+		var body wakeInfo
+		if err := decodeBody(w, r, &body); err != nil {
+			writeDecodeError(w, err)
 			return
 		}
 
-		key := hashInfo(&body)
+		identifier := wakeIdentifier(body.Identifier)
+		if len(identifier) == 0 {
+			writeError(w, http.StatusBadRequest, codeMissingField, "Identifier is required")
+			return
+		}
 
-		requestQueueLock.Lock()
-		if _, ok := requestQueue[key]; !ok {
-			requestQueue[key] = body
+		keys, scheduled, err := wakeSubscriptions(identifier)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, codeInternalError, "failed to list subscriptions")
+			return
 		}
-		requestQueueLock.Unlock()
 
-		response, _ := json.Marshal(struct {
-			Success bool `json:"success"`
+		writeJSON(w, http.StatusOK, struct {
+			Success   bool `json:"success"`
+			Found     bool `json:"found"`
+			Scheduled int  `json:"scheduled"`
 		}{
 			true,
+			len(keys) != 0,
+			scheduled,
 		})
+		return
+	}
 
-		w.Header().Add("Content-Type", "application/json")
-		w.Write(response)
+	rejectMethod(w, r, http.MethodPost)
+}
 
-		return
+func validateURL(raw string, field string) *apiError {
+	parsed, err := url.ParseRequestURI(raw)
+	if err != nil {
+		return newFieldError(codeInvalidURL, field, field+" is not a valid url")
+	}
+
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return newFieldError(codeInvalidURL, field, field+" must use http or https")
 	}
 
-	w.WriteHeader(http.StatusBadRequest)
+	if len(parsed.Host) == 0 {
+		return newFieldError(codeInvalidURL, field, field+" must include a host")
+	}
+
+	return nil
 }
 
-func gatherStatus(url string) *statusInfo {
-	collector := colly.NewCollector()
-	response := &statusInfo{}
+func validateHeaders(headers map[string]string) *apiError {
+	if len(headers) > maxHeaders {
+		return newFieldError(codeInvalidField, "headers", "at most "+strconv.Itoa(maxHeaders)+" headers are allowed")
+	}
 
-	collector.OnHTML(".profile_in_game_header", func(e *colly.HTMLElement) {
-		if strings.Contains(e.Text, "In-Game") {
-			response.IsPlaying = true
+	for name, value := range headers {
+		if !headerNamePattern.MatchString(name) {
+			return newFieldError(codeInvalidField, "headers", "header name "+strconv.Quote(name)+" is invalid")
 		}
-	})
 
-	collector.OnHTML(".recent_games .game_info", func(e *colly.HTMLElement) {
-		if len(response.GameName) == 0 {
-			response.GameName = e.ChildText(".game_name > a")
-			response.GameLink = e.ChildAttr(".game_info_cap > a", "href")
-			response.GameIcon = e.ChildAttr(".game_info_cap img", "src")
+		canonical := http.CanonicalHeaderKey(name)
+		if reservedHeaders[canonical] || strings.HasPrefix(canonical, "Proxy-") || strings.HasPrefix(canonical, "X-Steam-Status-") {
+			return newFieldError(codeInvalidField, "headers", "header "+name+" cannot be overridden")
 		}
-	})
 
-	collector.OnResponse(func(r *colly.Response) {
-		response.StatusCode = r.StatusCode
-	})
+		if len(value) > maxHeaderLength || strings.ContainsAny(value, "\r\n") {
+			return newFieldError(codeInvalidField, "headers", "header "+name+" has an invalid value")
+		}
+	}
 
-	collector.OnError(func(r *colly.Response, err error) {
-		response.StatusCode = r.StatusCode
-	})
+	return nil
+}
 
-	collector.Visit(url)
+func validateMetadata(r *requestInfo) *apiError {
+	trimmed := bytes.TrimSpace(r.Metadata)
+	if len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		r.Metadata = nil
+		return nil
+	}
 
-	return response
+	if trimmed[0] != '"' && trimmed[0] != '{' {
+		return newFieldError(codeInvalidField, "metadata", "metadata must be a string or an object")
+	}
+
+	compacted := bytes.Buffer{}
+	if err := json.Compact(&compacted, trimmed); err != nil {
+		return newFieldError(codeInvalidField, "metadata", "metadata must be valid JSON")
+	}
+
+	if compacted.Len() > maxMetadataBytes {
+		return newFieldError(codeInvalidField, "metadata", "metadata must not exceed "+strconv.Itoa(maxMetadataBytes)+" bytes")
+	}
+
+	r.Metadata = compacted.Bytes()
+	return nil
 }
 
-func restore(key string) {
-	delete(statusCache, key)
-	requestQueueLock.Lock()
-	delete(requestQueue, key)
-	requestQueueLock.Unlock()
+func expiresAt(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
 }
 
-func runUpdate() {
-	for {
-		requests := []requestInfo{}
+func maskHeaders(headers map[string]string) map[string]string {
+	if len(headers) == 0 {
+		return nil
+	}
 
-		requestQueueLock.Lock()
-		for _, info := range requestQueue {
-			requests = append(requests, info)
+	masked := make(map[string]string, len(headers))
+	for name, value := range headers {
+		masked[name] = maskToken(value)
+	}
+
+	return masked
+}
+
+func validateRequest(r *requestInfo) *apiError {
+	if len(r.Page) == 0 && len(r.SteamID) == 0 {
+		return newFieldError(codeMissingField, "page", "page or steamId is required")
+	}
+
+	r.Transport = strings.ToLower(r.Transport)
+	if r.Transport == "http" {
+		r.Transport = ""
+	}
+
+	published := len(r.Transport) != 0
+	if published && !publishTransport(r.Transport) {
+		return newFieldError(codeInvalidField, "transport", "transport "+r.Transport+" is not configured")
+	}
+
+	if len(r.Token) == 0 && !webhookFormat(r.Format) && !published {
+		return newFieldError(codeMissingField, "token", "token is required")
+	}
+
+	if published {
+		r.Callback = r.Transport + ":"
+	} else if len(r.Callback) == 0 {
+		return newFieldError(codeMissingField, "callback", "callback is required")
+	}
+
+	if len(r.SteamID) != 0 {
+		if !isSteamID(r.SteamID) {
+			return newFieldError(codeInvalidField, "steamId", "steamId must be a 17 digit id starting with 7656")
+		}
+
+		if len(r.Page) != 0 && normalizePage(r.Page) != profileURL(r.SteamID) {
+			return newFieldError(codeInvalidField, "steamId", "page and steamId refer to different profiles")
+		}
+
+		r.Page = profileURL(r.SteamID)
+	}
+
+	page, reason := canonicalPage(r.Page)
+	if len(reason) != 0 {
+		return newFieldError(codeInvalidURL, "page", reason)
+	}
+	r.Page = page
+
+	if resolved := resolvePage(page); resolved != page {
+		r.Original = page
+		r.Page = resolved
+	}
+
+	if id := steamIDFromPage(r.Page); len(id) != 0 {
+		r.SteamID = id
+	}
+
+	if r.Format != "" && r.Format != "form" && r.Format != "json" && !webhookFormat(r.Format) {
+		return newFieldError(codeInvalidField, "format", "format must be form, json, discord, or slack")
+	}
+
+	r.Method = strings.ToUpper(r.Method)
+	if r.Method != "" && r.Method != http.MethodPost && r.Method != http.MethodPut && r.Method != http.MethodPatch {
+		return newFieldError(codeInvalidField, "method", "method must be POST, PUT, or PATCH")
+	}
+
+	if err := validateHeaders(r.Headers); err != nil {
+		return err
+	}
+
+	if err := validateMetadata(r); err != nil {
+		return err
+	}
+
+	for i, transition := range r.NotifyOn {
+		r.NotifyOn[i] = strings.ToLower(transition)
+		if !notifyTransitions[r.NotifyOn[i]] {
+			return newFieldError(codeInvalidField, "notifyOn", "notifyOn must only contain start, stop, or change")
 		}
-		requestQueueLock.Unlock()
+	}
+
+	if r.TtlSeconds != 0 && r.TtlSeconds < minTTLSeconds {
+		return newFieldError(codeInvalidField, "ttlSeconds", "ttlSeconds must be at least "+strconv.Itoa(minTTLSeconds))
+	}
+
+	if r.TtlSeconds != 0 {
+		r.ExpiresAt = currentClock.Now().Add(time.Duration(r.TtlSeconds) * time.Second)
+	}
+
+	if r.IntervalSeconds != 0 && r.IntervalSeconds < minIntervalSeconds {
+		return newFieldError(codeInvalidField, "intervalSeconds", "intervalSeconds must be at least "+strconv.Itoa(minIntervalSeconds))
+	}
+
+	if published {
+		return checkPageHost(r.Page)
+	}
+
+	if err := validateURL(r.Callback, "callback"); err != nil {
+		return err
+	}
+
+	if requireHTTPSCallbacks && !strings.HasPrefix(strings.ToLower(r.Callback), "https://") {
+		return newFieldError(codeInvalidURL, "callback", "callback must use https")
+	}
+
+	if err := checkPageHost(r.Page); err != nil {
+		return err
+	}
+
+	if err := checkPublicHost(r.Callback); err != nil {
+		return newFieldError(codeForbidden, "callback", "callback host is not allowed: "+err.Error())
+	}
+
+	return nil
+}
 
-		for _, info := range requests {
-			response := gatherStatus(info.Page)
+func checkPageHost(page string) *apiError {
+	if err := checkPublicHost(page); err != nil {
+		return newFieldError(codeForbidden, "page", "page host is not allowed: "+err.Error())
+	}
 
-			if response.StatusCode == 200 {
-				key := hashInfo(&info)
-				dump := hashStatus(response, &info)
+	return nil
+}
 
-				if item, ok := statusCache[key]; ok && item == dump {
-					continue
-				}
+type enqueueResult struct {
+	created bool
+	err     error
+}
 
-				statusCache[key] = dump
+func enqueueRequest(info requestInfo) (bool, error) {
+	result := enqueueRequests([]requestInfo{info})[0]
+	return result.created, result.err
+}
 
-				form := url.Values{}
-				form.Add("page", info.Page)
-				form.Add("gameName", response.GameName)
-				form.Add("gameLink", response.GameLink)
-				form.Add("gameIcon", response.GameIcon)
-				form.Add("isPlaying", strconv.FormatBool(response.IsPlaying))
+func enqueueRequests(requests []requestInfo) []enqueueResult {
+	defer markStateDirty()
 
-				req, err := http.NewRequest("POST", info.Callback, strings.NewReader(form.Encode()))
-				if err != nil {
-					delete(statusCache, key)
-					continue
-				}
+	updateLock.Lock()
+	defer updateLock.Unlock()
 
-				req.Header.Add("API-Route", "Steam")
-				req.Header.Add("API-Token", info.Token)
-				req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	results := make([]enqueueResult, len(requests))
+	for i := range requests {
+		results[i].created, results[i].err = storeRequest(requests[i])
+	}
 
-				callback, err := client.Do(req)
-				if err != nil {
-					restore(key)
-					continue
-				}
+	return results
+}
 
-				defer callback.Body.Close()
-				body, err := ioutil.ReadAll(callback.Body)
-				if err != nil {
-					restore(key)
-					continue
-				}
+func storeRequest(info requestInfo) (bool, error) {
+	key := subscriptionID(&info)
 
-				jsonBody := callbackInfo{}
+	current, ok, err := subscriptions.Get(key)
+	if err != nil {
+		return false, err
+	}
 
-				if json.Unmarshal(body, &jsonBody) != nil || !jsonBody.Success || len(jsonBody.Data.Refresh) == 0 {
-					restore(key)
-					continue
-				}
+	if !ok {
+		if limit := currentConfig.Load().MaxSubscriptions; limit > 0 {
+			count, err := subscriptions.Count()
+			if err != nil {
+				return false, err
+			}
 
-				requestQueueLock.Lock()
-				copy := requestQueue[key]
-				copy.Token = jsonBody.Data.Refresh
-				requestQueue[key] = copy
-				requestQueueLock.Unlock()
+			if count >= limit {
+				recordRejectedSubscription()
+				return false, errQueueFull
 			}
+		}
+	}
+
+	if ok {
+		info.Page = current.Page
+		info.SteamID = current.SteamID
+		info.Original = current.Original
+		info.Owner = current.Owner
+		info.Sequence = current.Sequence
+
+		err = subscriptions.Put(key, info)
+	} else if _, err = subscriptions.Add(key, info); err == nil {
+		recordSubscription("added")
+	}
 
-			time.Sleep(3000 * time.Millisecond)
+	if err != nil {
+		return false, err
+	}
+
+	if info.SendInitial {
+		if err := subscriptions.DeleteStatus(key); err != nil {
+			return !ok, err
 		}
+		scheduleWake([]string{key})
+	}
+
+	return !ok, nil
+}
 
-		time.Sleep(30000 * time.Millisecond)
+func dequeueRequest(key string) (bool, error) {
+	ok, err := subscriptions.Delete(key)
+	if err != nil || !ok {
+		return false, err
 	}
+
+	recordSubscription("removed")
+
+	forgetSubscriptionState(key)
+	markStateDirty()
+
+	return true, nil
 }
 
-func main() {
-	client = http.Client{}
-	statusCache = make(map[string]string)
-	requestQueue = make(map[string]requestInfo)
+func storeSubscription(info *requestInfo) (string, bool, int, *apiError) {
+	if err := validateRequest(info); err != nil {
+		slog.Info("Rejected subscription", "pageHost", pageHost(info.Page), "field", err.Field, "err", err.Message)
+		return "", false, http.StatusBadRequest, err
+	}
+
+	if !callbackAllowed(info) {
+		return "", false, http.StatusForbidden, newFieldError(codeForbidden, "callback", "callback host is not allowed")
+	}
 
-	http.HandleFunc("/", indexHandler)
-	http.HandleFunc("/wake", wakeHandler)
-	http.HandleFunc("/lookup", lookupHandler)
+	key := subscriptionID(info)
+	created, err := enqueueRequest(*info)
+	if errors.Is(err, errQueueFull) {
+		slog.Warn("Rejected subscription because the queue is full", subscriptionAttrs(key, info)...)
+		return key, false, http.StatusTooManyRequests, newAPIError(codeQueueFull, "subscription limit reached")
+	}
+	if err != nil {
+		slog.Error("Failed to store subscription", append(subscriptionAttrs(key, info), "err", err)...)
+		return key, false, http.StatusInternalServerError, newAPIError(codeInternalError, "failed to store subscription")
+	}
+	slog.Info("Stored subscription", append(subscriptionAttrs(key, info), "created", created)...)
+
+	return key, created, http.StatusOK, nil
+}
+
+func lookupHandler(w http.ResponseWriter, r *http.Request) {
+	if rejectDraining(w) {
+		return
+	}
 
-	go runUpdate()
+	if r.Method == http.MethodDelete {
+		unsubscribeHandler(w, r)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		owner, ok := authorize(w, r)
+		if !ok || !requireJSON(w, r) {
+			return
+		}
+
+		var body batchRequestInfo
+		if err := decodeBody(w, r, &body); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		if body.Requests != nil {
+			for i := range body.Requests {
+				body.Requests[i].Owner = owner
+				applyVersionDefaults(r, &body.Requests[i])
+			}
+
+			batchLookup(w, body.Requests)
+			return
+		}
+
+		applyVersionDefaults(r, &body.requestInfo)
+		body.requestInfo.Owner = owner
+
+		key, created, status, err := storeSubscription(&body.requestInfo)
+		if status == http.StatusTooManyRequests {
+			rejectQueueFull(w)
+			return
+		}
+		if err != nil {
+			writeAPIError(w, status, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, struct {
+			Success bool   `json:"success"`
+			ID      string `json:"id"`
+			Created bool   `json:"created"`
+		}{
+			true,
+			key,
+			created,
+		})
+
+		return
+	}
+
+	rejectMethod(w, r, http.MethodPost, http.MethodDelete)
+}
+
+func batchLookup(w http.ResponseWriter, requests []requestInfo) {
+	type batchResult struct {
+		ID       string    `json:"id,omitempty"`
+		Page     string    `json:"page"`
+		Callback string    `json:"callback"`
+		Success  bool      `json:"success"`
+		Created  bool      `json:"created"`
+		Error    *apiError `json:"error,omitempty"`
+	}
+
+	results := []batchResult{}
+	valid := []requestInfo{}
+	indexes := []int{}
+
+	for i := range requests {
+		err := validateRequest(&requests[i])
+		if err == nil && !callbackAllowed(&requests[i]) {
+			err = newFieldError(codeForbidden, "callback", "callback host is not allowed")
+		}
+
+		results = append(results, batchResult{
+			Page:     requests[i].Page,
+			Callback: requests[i].Callback,
+			Error:    err,
+		})
+
+		if err == nil {
+			valid = append(valid, requests[i])
+			indexes = append(indexes, i)
+		}
+	}
+
+	accepted := 0
+	for i, stored := range enqueueRequests(valid) {
+		result := &results[indexes[i]]
+
+		switch {
+		case errors.Is(stored.err, errQueueFull):
+			result.Error = newAPIError(codeQueueFull, "subscription limit reached")
+		case stored.err != nil:
+			slog.Error("Failed to store subscription", append(subscriptionAttrs(subscriptionID(&valid[i]), &valid[i]), "err", stored.err)...)
+			result.Error = newAPIError(codeInternalError, "failed to store subscription")
+		default:
+			result.ID = subscriptionID(&valid[i])
+			result.Created = stored.created
+			result.Success = true
+			accepted++
+		}
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Success  bool          `json:"success"`
+		Accepted int           `json:"accepted"`
+		Results  []batchResult `json:"results"`
+	}{
+		accepted == len(requests),
+		accepted,
+		results,
+	})
+}
+
+func unsubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodDelete || r.Method == http.MethodPost {
+		if _, ok := authorize(w, r); !ok {
+			return
+		}
+
+		var body subscriptionRef
+		if err := decodeBody(w, r, &body); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		key, apiErr := subscriptionKey(body.ID, &body.requestInfo)
+		if apiErr != nil {
+			writeAPIError(w, http.StatusBadRequest, apiErr)
+			return
+		}
+
+		found, err := dequeueRequest(key)
+		if err != nil {
+			slog.Error("Failed to remove subscription", "key", key, "err", err)
+			writeError(w, http.StatusInternalServerError, codeInternalError, "failed to remove subscription")
+			return
+		}
+
+		if !found {
+			writeError(w, http.StatusNotFound, codeNotFound, "subscription not found")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, struct {
+			Success bool `json:"success"`
+		}{
+			true,
+		})
+
+		return
+	}
+
+	rejectMethod(w, r, http.MethodPost, http.MethodDelete)
+}
+
+func renewHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		if _, ok := authorize(w, r); !ok {
+			return
+		}
+
+		var body subscriptionRef
+		if err := decodeBody(w, r, &body); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		key, apiErr := subscriptionKey(body.ID, &body.requestInfo)
+		if apiErr != nil {
+			writeAPIError(w, http.StatusBadRequest, apiErr)
+			return
+		}
+
+		updateLock.Lock()
+		defer updateLock.Unlock()
+
+		info, found, err := subscriptions.Get(key)
+		if err != nil {
+			slog.Error("Failed to read subscription", "key", key, "err", err)
+			writeError(w, http.StatusInternalServerError, codeInternalError, "failed to read subscription")
+			return
+		}
+
+		if !found {
+			writeError(w, http.StatusNotFound, codeNotFound, "subscription not found")
+			return
+		}
+
+		if info.TtlSeconds == 0 {
+			writeError(w, http.StatusBadRequest, codeInvalidField, "subscription has no ttl")
+			return
+		}
+
+		info.ExpiresAt = currentClock.Now().Add(time.Duration(info.TtlSeconds) * time.Second)
+		if err := subscriptions.Put(key, info); err != nil {
+			slog.Error("Failed to renew subscription", "key", key, "err", err)
+			writeError(w, http.StatusInternalServerError, codeInternalError, "failed to renew subscription")
+			return
+		}
+		markStateDirty()
+
+		writeJSON(w, http.StatusOK, struct {
+			Success   bool      `json:"success"`
+			ID        string    `json:"id"`
+			ExpiresAt time.Time `json:"expiresAt"`
+		}{
+			true,
+			key,
+			info.ExpiresAt,
+		})
+
+		return
+	}
+
+	rejectMethod(w, r, http.MethodPost)
+}
+
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		page := r.URL.Query().Get("page")
+		if len(page) == 0 {
+			writeError(w, http.StatusBadRequest, codeMissingField, "page is required")
+			return
+		}
+
+		page, reason := canonicalPage(page)
+		if len(reason) != 0 {
+			writeError(w, http.StatusBadRequest, codeInvalidURL, reason)
+			return
+		}
+
+		response, ok := onDemandStatus(r.Context(), page)
+		if !ok {
+			writeError(w, http.StatusServiceUnavailable, codeUnavailable, "steam is throttled or unavailable, retry later")
+			return
+		}
+
+		if response.StatusCode != 200 {
+			writeJSON(w, http.StatusBadGateway, struct {
+				Success    bool      `json:"success"`
+				Error      *apiError `json:"error"`
+				StatusCode int       `json:"statusCode"`
+			}{
+				false,
+				newAPIError(codeUpstreamError, "failed to gather status"),
+				response.StatusCode,
+			})
+			return
+		}
+
+		if prefersText(w, r) {
+			writeText(w, http.StatusOK, statusText(page, response))
+			return
+		}
+
+		writeJSON(w, http.StatusOK, struct {
+			Success bool        `json:"success"`
+			Status  *statusInfo `json:"status"`
+		}{
+			true,
+			response,
+		})
+		return
+	}
+
+	rejectMethod(w, r, http.MethodGet)
+}
+
+func cachedStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		info := requestInfo{
+			Page:     r.URL.Query().Get("page"),
+			Callback: r.URL.Query().Get("callback"),
+		}
+
+		key, apiErr := subscriptionKey(r.URL.Query().Get("id"), &info)
+		if apiErr != nil {
+			writeAPIError(w, http.StatusBadRequest, apiErr)
+			return
+		}
+
+		entry, ok, err := subscriptions.GetStatus(key)
+		if err != nil {
+			slog.Error("Failed to read status", "key", key, "err", err)
+			writeError(w, http.StatusInternalServerError, codeInternalError, "failed to read status")
+			return
+		}
+
+		if !ok {
+			writeError(w, http.StatusNotFound, codeNotFound, "no cached status")
+			return
+		}
+
+		text := prefersText(w, r)
+		if checkETag(w, r, hashETag(entry.Hash+"|"+strconv.FormatBool(text))) {
+			return
+		}
+
+		if text {
+			page := entry.Status.ResolvedPage
+			if len(page) == 0 {
+				page = info.Page
+			}
+			writeText(w, http.StatusOK, statusText(page, &entry.Status)+"updated: "+entry.Updated.UTC().Format(time.RFC3339)+"\n")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, struct {
+			Success bool       `json:"success"`
+			Status  statusInfo `json:"status"`
+			Updated time.Time  `json:"updated"`
+		}{
+			true,
+			entry.Status,
+			entry.Updated,
+		})
+		return
+	}
+
+	rejectMethod(w, r, http.MethodGet)
+}
+
+func subscriptionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		type subscriptionEntry struct {
+			ID       string `json:"id,omitempty"`
+			Page     string `json:"page"`
+			Callback string `json:"callback"`
+			Token    string `json:"token"`
+			Owner    string `json:"owner,omitempty"`
+			LastHash string `json:"lastHash"`
+
+			IntervalSeconds int               `json:"intervalSeconds,omitempty"`
+			Metadata        json.RawMessage   `json:"metadata,omitempty"`
+			NextPollAt      *time.Time        `json:"nextPollAt,omitempty"`
+			Headers         map[string]string `json:"headers,omitempty"`
+			ExpiresAt       *time.Time        `json:"expiresAt,omitempty"`
+			Failures        int               `json:"failures"`
+			BackoffUntil    *time.Time        `json:"backoffUntil,omitempty"`
+			CallbackBreaker string            `json:"callbackBreaker"`
+		}
+
+		text := prefersText(w, r)
+		tag := subscriptionsETag()
+		if text {
+			tag = strings.TrimSuffix(tag, `"`) + `-text"`
+		}
+		if checkETag(w, r, tag) {
+			return
+		}
+
+		requests, err := subscriptions.List()
+		if err != nil {
+			slog.Error("Failed to list subscriptions", "err", err)
+			writeError(w, http.StatusInternalServerError, codeInternalError, "failed to list subscriptions")
+			return
+		}
+
+		statuses, err := subscriptions.ListStatuses()
+		if err != nil {
+			slog.Error("Failed to list statuses", "err", err)
+			writeError(w, http.StatusInternalServerError, codeInternalError, "failed to list statuses")
+			return
+		}
+
+		entries := []subscriptionEntry{}
+		keys := []string{}
+
+		for key, info := range requests {
+			keys = append(keys, key)
+
+			id := ""
+			if versioned(r) {
+				id = key
+			}
+
+			entries = append(entries, subscriptionEntry{
+				ID:       id,
+				Page:     info.Page,
+				Callback: info.Callback,
+				Token:    maskToken(info.Token),
+				Owner:    info.Owner,
+
+				IntervalSeconds: info.IntervalSeconds,
+				Metadata:        info.Metadata,
+				Headers:         maskHeaders(info.Headers),
+				ExpiresAt:       expiresAt(info.ExpiresAt),
+
+				LastHash:        statuses[key].Hash,
+				CallbackBreaker: callbackBreakerState(pageHost(info.Callback)).String(),
+			})
+		}
+
+		subscriptionStatesLock.Lock()
+		for i := range entries {
+			state := subscriptionStates[keys[i]]
+			if !state.NextPollAt.IsZero() {
+				entries[i].NextPollAt = &state.NextPollAt
+			}
+			entries[i].Failures = state.Failures
+			if state.BackoffUntil.After(currentClock.Now()) {
+				entries[i].BackoffUntil = &state.BackoffUntil
+			}
+		}
+		subscriptionStatesLock.Unlock()
+
+		if text {
+			summary := strings.Builder{}
+			for _, entry := range entries {
+				fmt.Fprintf(&summary, "%s %s failures=%d breaker=%s\n", entry.Page, pageHost(entry.Callback), entry.Failures, entry.CallbackBreaker)
+			}
+			writeText(w, http.StatusOK, summary.String())
+			return
+		}
+
+		writeJSON(w, http.StatusOK, struct {
+			Count         int                 `json:"count"`
+			Subscriptions []subscriptionEntry `json:"subscriptions"`
+		}{
+			len(entries),
+			entries,
+		})
+		return
+	}
+
+	rejectMethod(w, r, http.MethodGet)
+}
+
+type contextTransport struct {
+	ctx       context.Context
+	base      http.RoundTripper
+	redirects []int
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	response, err := t.base.RoundTrip(req.WithContext(t.ctx))
+	if err == nil && response.StatusCode >= 300 && response.StatusCode < 400 {
+		t.redirects = append(t.redirects, response.StatusCode)
+	}
+
+	return response, err
+}
+
+func (t *contextTransport) permanentRedirect() bool {
+	if len(t.redirects) == 0 {
+		return false
+	}
+
+	for _, code := range t.redirects {
+		if code != http.StatusMovedPermanently && code != http.StatusPermanentRedirect {
+			return false
+		}
+	}
+
+	return true
+}
+
+func redirectHandler(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("too many redirects")
+	}
+
+	for _, previous := range via {
+		if previous.URL.String() == req.URL.String() {
+			return errors.New("redirect loop detected")
+		}
+	}
+
+	return nil
+}
+
+func sleepContext(ctx context.Context, duration time.Duration) bool {
+	if duration <= 0 {
+		return ctx.Err() == nil
+	}
+
+	select {
+	case <-currentClock.After(duration):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func scrapeError(err error) string {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return "timeout"
+	}
+
+	if isProxyError(err) {
+		return "proxy"
+	}
+
+	return err.Error()
+}
+
+func retryableStatus(code int) bool {
+	return code == 0 || code >= 500
+}
+
+func onDemandStatus(ctx context.Context, page string) (*statusInfo, bool) {
+	ctx, cancel := context.WithTimeout(ctx, statusTimeout)
+	defer cancel()
+
+	if !scrapeBreaker.allow() {
+		return nil, false
+	}
+
+	settings := currentConfig.Load()
+	if !waitThrottle(ctx) || !scrapePacer.wait(ctx, jittered(settings.PollDelay.Duration, settings.Jitter)) {
+		return nil, false
+	}
+
+	response := gatherStatus(ctx, pageScraper, page)
+	scrapeBreaker.record(!retryableStatus(response.StatusCode))
+
+	return response, true
+}
+
+func gatherStatus(ctx context.Context, source scraper, url string) *statusInfo {
+	ctx, span := tracer.Start(ctx, "gatherStatus", trace.WithAttributes(attribute.String("steam.page", url)))
+	defer span.End()
+
+	delay := scrapeRetryDelay
+
+	for attempt := 1; ; attempt++ {
+		observed := currentClock.Now().UTC()
+		response := scrapeAttempt(ctx, source, url)
+		response.Attempts = attempt
+		response.ObservedAt = observed
+
+		if response.StatusCode == http.StatusTooManyRequests {
+			recordThrottle(response.RetryAfter)
+		} else if response.StatusCode == 200 {
+			recordThrottleRecovery()
+		}
+
+		if response.StatusCode == 200 {
+			slog.Debug("Scraped profile", "page", url, "status", response.StatusCode, "attempt", attempt)
+		} else {
+			slog.Warn("Failed to scrape profile", "page", url, "status", response.StatusCode, "attempt", attempt, "err", response.Error)
+		}
+
+		if !retryableStatus(response.StatusCode) || attempt >= scrapeAttempts || !sleepContext(ctx, delay) {
+			finishScrapeSpan(span, response)
+			return response
+		}
+		delay *= 2
+	}
+}
+
+func scrapeAttempt(ctx context.Context, source scraper, url string) *statusInfo {
+	ctx, cancel := context.WithTimeout(ctx, scrapeTimeout)
+	defer cancel()
+
+	response, err := source.Scrape(ctx, url)
+	if response == nil {
+		response = &statusInfo{Level: -1}
+	}
+	if err != nil && len(response.Error) == 0 {
+		response.Error = scrapeError(err)
+	}
+
+	return response
+}
+
+func scrapeStatus(ctx context.Context, client *http.Client, url string) *statusInfo {
+	collector := colly.NewCollector(colly.AllowedDomains("steamcommunity.com", "www.steamcommunity.com"))
+	collector.RedirectHandler = redirectHandler
+	response := &statusInfo{Level: -1}
+	current := gameEntry{HoursOnRecord: -1, HoursPastTwoWeeks: -1}
+	recent := gameEntry{HoursOnRecord: -1, HoursPastTwoWeeks: -1}
+	started := time.Now()
+
+	transport := &contextTransport{ctx: ctx, base: client.Transport}
+	collector.WithTransport(transport)
+
+	timeout := scrapeTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	collector.SetRequestTimeout(timeout)
+	collector.MaxBodySize = scrapeMaxBytes
+
+	collector.OnHTML(".profile_in_game_header", func(e *colly.HTMLElement) {
+		if lastOnline, ok := parseLastOnline(e.Text, currentClock.Now()); ok {
+			response.LastOnline = lastOnline.UTC().Format(time.RFC3339)
+		}
+	})
+
+	collector.OnHTML(".profile_in_game", func(e *colly.HTMLElement) {
+		header := strings.ToLower(e.ChildText(".profile_in_game_header"))
+
+		playing := e.DOM.HasClass("in-game")
+		if !playing && !e.DOM.HasClass("online") && !e.DOM.HasClass("offline") {
+			playing = strings.Contains(header, "in-game")
+		}
+
+		if playing {
+			response.IsPlaying = true
+		}
+
+		switch {
+		case playing:
+			response.OnlineState = "in-game"
+		case strings.Contains(header, "away") || strings.Contains(header, "snooze"):
+			response.OnlineState = "away"
+		case e.DOM.HasClass("online"):
+			response.OnlineState = "online"
+		case e.DOM.HasClass("offline"):
+			response.OnlineState = "offline"
+		}
+
+		current.Name = e.ChildText(".profile_in_game_name")
+		current.Link, _ = e.DOM.Find("a[href*='/app/']").First().Attr("href")
+		if len(current.Link) == 0 {
+			current.Link, _ = e.DOM.Closest("a[href*='/app/']").Attr("href")
+		}
+		current.Icon = e.ChildAttr("img", "src")
+	})
+
+	collector.OnHTML(".persona_level .friendPlayerLevelNum", func(e *colly.HTMLElement) {
+		if level, err := parseLevel(e.Text); err == nil {
+			response.Level = level
+		}
+	})
+
+	collector.OnHTML(".actual_persona_name", func(e *colly.HTMLElement) {
+		if len(response.PersonaName) == 0 {
+			response.PersonaName = strings.TrimSpace(e.Text)
+		}
+	})
+
+	collector.OnHTML(".playerAvatarAutoSizeInner", func(e *colly.HTMLElement) {
+		if src, ok := e.DOM.Find("img").Not(".profile_avatar_frame img").Last().Attr("src"); ok && len(response.AvatarURL) == 0 {
+			response.AvatarURL = normalizeAvatar(src)
+		}
+	})
+
+	collector.OnHTML(".profile_private_info", func(e *colly.HTMLElement) {
+		if strings.Contains(strings.ToLower(e.Text), "friends") {
+			response.Visibility = "friendsOnly"
+		} else {
+			response.Visibility = "private"
+		}
+	})
+
+	collector.OnHTML(".error_ctn, #message", func(e *colly.HTMLElement) {
+		if strings.Contains(e.Text, "The specified profile could not be found") {
+			response.ProfileMissing = true
+		}
+	})
+
+	collector.OnHTML(".recent_games .game_info", func(e *colly.HTMLElement) {
+		details := e.ChildText(".game_info_details")
+		entry := gameEntry{
+			Name:              e.ChildText(".game_name > a"),
+			Link:              e.ChildAttr(".game_info_cap > a", "href"),
+			Icon:              e.ChildAttr(".game_info_cap img", "src"),
+			HoursOnRecord:     parseHours(details, "on record"),
+			HoursPastTwoWeeks: parseHours(details, "past 2 weeks"),
+		}
+
+		if len(recent.Name) == 0 {
+			recent = entry
+		}
+
+		if len(response.RecentGames) < 3 {
+			response.RecentGames = append(response.RecentGames, entry)
+		}
+	})
+
+	collector.OnResponse(func(r *colly.Response) {
+		response.StatusCode = r.StatusCode
+
+		resolved := *r.Request.URL
+		query := resolved.Query()
+		query.Del("l")
+		resolved.RawQuery = query.Encode()
+		response.ResolvedPage = normalizePage(resolved.String())
+	})
+
+	collector.OnError(func(r *colly.Response, err error) {
+		response.StatusCode = r.StatusCode
+		response.Error = scrapeError(err)
+		if r.StatusCode == http.StatusTooManyRequests && r.Headers != nil {
+			response.RetryAfter = parseRetryAfter(r.Headers.Get("Retry-After"))
+		}
+		if r.StatusCode == http.StatusNotFound {
+			response.ProfileMissing = true
+		}
+	})
+
+	collector.SetCookies("https://steamcommunity.com", []*http.Cookie{{Name: "Steam_Language", Value: "english"}})
+	collector.Visit(englishURL(url))
+	recordScrape(response.StatusCode, started)
+
+	game := recent
+	if response.IsPlaying {
+		game = current
+		if len(game.Name) != 0 && game.Name == recent.Name {
+			if len(game.Link) == 0 {
+				game.Link = recent.Link
+			}
+			if len(game.Icon) == 0 {
+				game.Icon = recent.Icon
+			}
+			game.HoursOnRecord = recent.HoursOnRecord
+			game.HoursPastTwoWeeks = recent.HoursPastTwoWeeks
+		}
+	}
+
+	response.GameName = game.Name
+	response.GameLink = game.Link
+	response.GameIcon = game.Icon
+	response.HoursOnRecord = game.HoursOnRecord
+	response.HoursPastTwoWeeks = game.HoursPastTwoWeeks
+
+	response.AppID = parseAppID(response.GameLink)
+	response.PermanentRedirect = transport.permanentRedirect() && response.ResolvedPage != normalizePage(url)
+
+	if response.StatusCode == 200 {
+		response.Source = "html"
+
+		if len(response.Visibility) == 0 {
+			response.Visibility = "public"
+		}
+	}
+
+	if response.StatusCode == 200 && response.IsPlaying && len(response.GameName) == 0 {
+		if profile, err := fetchProfileXML(ctx, client, url); err == nil && len(profile.InGameInfo.GameName) != 0 {
+			response.GameName = profile.InGameInfo.GameName
+			response.GameLink = profile.InGameInfo.GameLink
+			response.GameIcon = profile.InGameInfo.GameIcon
+			response.AppID = parseAppID(response.GameLink)
+			response.Visibility = profile.visibility()
+			if len(response.PersonaName) == 0 {
+				response.PersonaName = strings.TrimSpace(profile.SteamID)
+			}
+			if len(response.AvatarURL) == 0 {
+				response.AvatarURL = normalizeAvatar(strings.TrimSpace(profile.AvatarFull))
+			}
+			response.Source = "xml"
+		}
+	}
+
+	return response
+}
+
+func recordScrapeResult(key string, response *statusInfo, interval time.Duration) {
+	subscriptionStatesLock.Lock()
+	state := subscriptionStates[key]
+	state.LastScrape = response.StatusCode
+
+	if response.StatusCode == 200 || response.ProfileMissing {
+		state.Failures = 0
+		state.BackoffUntil = time.Time{}
+	} else if response.StatusCode != http.StatusTooManyRequests {
+		state.Failures++
+		backoff := interval
+		for i := 0; i < state.Failures && backoff < maxBackoff; i++ {
+			backoff *= 2
+		}
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+		state.BackoffUntil = currentClock.Now().Add(backoff)
+	}
+
+	subscriptionStates[key] = state
+	subscriptionStatesLock.Unlock()
+	subscriptionsRevision.Add(1)
+}
+
+func recordDeliveryResult(key string, outcome string) {
+	subscriptionStatesLock.Lock()
+	state := subscriptionStates[key]
+	state.LastDelivery = outcome
+	state.LastDeliveryAt = currentClock.Now()
+	subscriptionStates[key] = state
+	subscriptionStatesLock.Unlock()
+}
+
+func recordMissing(key string, missing bool) int {
+	subscriptionStatesLock.Lock()
+	defer subscriptionStatesLock.Unlock()
+
+	state := subscriptionStates[key]
+	if missing {
+		state.MissingCount++
+	} else {
+		state.MissingCount = 0
+	}
+	subscriptionStates[key] = state
+
+	return state.MissingCount
+}
+
+func pollDue(key string, info *requestInfo, now time.Time) bool {
+	subscriptionStatesLock.Lock()
+	defer subscriptionStatesLock.Unlock()
+
+	state := subscriptionStates[key]
+	if now.Before(state.NextPollAt) || now.Before(state.BackoffUntil) {
+		return false
+	}
+
+	if info.IntervalSeconds == 0 {
+		return true
+	}
+	state.NextPollAt = now.Add(time.Duration(info.IntervalSeconds) * time.Second)
+	subscriptionStates[key] = state
+	subscriptionsRevision.Add(1)
+
+	return true
+}
+
+func pollNextCycle(key string) {
+	subscriptionStatesLock.Lock()
+	state := subscriptionStates[key]
+	state.NextPollAt = time.Time{}
+	state.BackoffUntil = time.Time{}
+	subscriptionStates[key] = state
+	subscriptionStatesLock.Unlock()
+	subscriptionsRevision.Add(1)
+}
+
+func forgetSubscriptionState(key string) {
+	subscriptionStatesLock.Lock()
+	delete(subscriptionStates, key)
+	subscriptionStatesLock.Unlock()
+}
+
+func movePage(key string, info requestInfo, page string) requestInfo {
+	updateLock.Lock()
+	defer updateLock.Unlock()
+
+	current, ok, err := subscriptions.Get(key)
+	if err != nil || !ok {
+		return info
+	}
+
+	if len(current.Original) == 0 {
+		current.Original = current.Page
+	}
+	current.Page = page
+	current.SteamID = steamIDFromPage(page)
+
+	if err := subscriptions.Put(key, current); err != nil {
+		slog.Error("Failed to move subscription", "key", key, "err", err)
+		return info
+	}
+
+	slog.Info("Moved subscription", "key", key, "from", info.Page, "to", page)
+	markStateDirty()
+
+	return current
+}
+
+func sweepStatusCache() (int, error) {
+	requests, err := subscriptions.List()
+	if err != nil {
+		return 0, err
+	}
+
+	statuses, err := subscriptions.ListStatuses()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for key := range statuses {
+		if _, ok := requests[key]; ok {
+			continue
+		}
+
+		if err := subscriptions.DeleteStatus(key); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+
+	if removed != 0 {
+		markStateDirty()
+	}
+
+	return removed, nil
+}
+
+func runCacheSweep(interval time.Duration) {
+	for {
+		<-currentClock.After(interval)
+		removed, err := sweepStatusCache()
+		if err != nil {
+			slog.Error("Failed to sweep status cache", "err", err)
+		}
+		if removed != 0 {
+			slog.Info("Swept orphaned status cache entries", "removed", removed)
+		}
+	}
+}
+
+func restore(key string, info *requestInfo, outcome string, reason string) {
+	if err := subscriptions.DeleteStatus(key); err != nil {
+		slog.Error("Failed to invalidate status", "key", key, "err", err)
+	}
+	markStateDirty()
+
+	recordDeliveryResult(key, outcome)
+	failures, err := subscriptions.AddFailure(key)
+	if err != nil {
+		slog.Error("Failed to record delivery failure", "key", key, "err", err)
+	}
+	slog.Warn("Callback failed", append(subscriptionAttrs(key, info), "failures", failures, "threshold", deliveryFailureThreshold, "err", reason)...)
+
+	if failures < deliveryFailureThreshold {
+		return
+	}
+
+	slog.Warn("Removing subscription after repeated delivery failures", append(subscriptionAttrs(key, info), "failures", failures)...)
+	dequeueRequest(key)
+}
+
+func expireSubscription(ctx context.Context, deliveries chan<- deliveryJob, key string, info requestInfo) {
+	if removed, _ := dequeueRequest(key); !removed {
+		return
+	}
+	slog.Info("Subscription expired", subscriptionAttrs(key, &info)...)
+
+	if !info.NotifyExpired {
+		return
+	}
+
+	select {
+	case deliveries <- deliveryJob{key: key, info: info, expired: true}:
+	case <-ctx.Done():
+	}
+}
+
+func processResult(ctx context.Context, deliveries chan<- deliveryJob, settings *config, info requestInfo, response *statusInfo) bool {
+	key := subscriptionID(&info)
+
+	if response.StatusCode == 200 && response.PermanentRedirect {
+		info = movePage(key, info, response.ResolvedPage)
+	}
+
+	interval := settings.CycleInterval.Duration
+	if info.IntervalSeconds != 0 {
+		interval = time.Duration(info.IntervalSeconds) * time.Second
+	}
+	recordScrapeResult(key, response, interval)
+
+	if response.StatusCode != 200 && !response.ProfileMissing {
+		return true
+	}
+
+	if count := recordMissing(key, response.ProfileMissing); count >= missingThreshold {
+		slog.Warn("Removing subscription after repeated missing profiles", append(subscriptionAttrs(key, &info), "missing", count)...)
+		dequeueRequest(key)
+		return true
+	}
+
+	previous, hadPrevious, err := previousStatus(key, &info)
+	if err != nil {
+		slog.Error("Failed to read previous status", "key", key, "err", err)
+	}
+
+	dump := hashStatus(response, &info)
+
+	changed, err := subscriptions.SwapStatus(key, cacheEntry{Hash: dump, Status: *response, Updated: currentClock.Now()})
+	if err != nil {
+		slog.Error("Failed to store status", "key", key, "err", err)
+		return true
+	}
+
+	if !changed {
+		return true
+	}
+
+	info, err = nextSequence(key, info)
+	if err != nil {
+		slog.Error("Failed to advance sequence", "key", key, "err", err)
+	}
+	markStateDirty()
+	hub.publish(key, &info, response)
+
+	if hadPrevious && !wantsTransition(&info, statusTransition(&previous.Status, response)) {
+		return true
+	}
+
+	select {
+	case deliveries <- deliveryJob{key: key, info: info, response: response}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func runUpdate(ctx context.Context, source scraper, client *http.Client) {
+	deliveries, waitDeliveries := startDeliveries(ctx, client)
+	defer waitDeliveries()
+
+	settings := currentConfig.Load()
+	next := currentClock.After(time.Duration(rand.Float64() * settings.Jitter * float64(settings.CycleInterval.Duration)))
+
+	for {
+		select {
+		case <-next:
+			delay, ok := runCycle(ctx, source, deliveries)
+			if !ok {
+				return
+			}
+			next = currentClock.After(delay)
+		case key := <-wakeups:
+			if !runWakeup(ctx, source, deliveries, key) {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func runCycle(ctx context.Context, source scraper, deliveries chan<- deliveryJob) (time.Duration, bool) {
+	settings := currentConfig.Load()
+
+	if paused.Load() {
+		return settings.CycleInterval.Duration, true
+	}
+
+	if steamDown() {
+		slog.Warn("Skipping update cycle while Steam is unreachable")
+		return settings.CycleInterval.Duration, true
+	}
+
+	queue, err := subscriptions.List()
+	if err != nil {
+		slog.Error("Failed to list subscriptions", "err", err)
+		return settings.CycleInterval.Duration, true
+	}
+
+	cycleCtx, cycleSpan := tracer.Start(ctx, "updateCycle", trace.WithNewRoot(), trace.WithAttributes(attribute.Int("steam.subscriptions", len(queue))))
+
+	requests := []requestInfo{}
+	now := currentClock.Now()
+
+	for key, info := range queue {
+		if !info.ExpiresAt.IsZero() && now.After(info.ExpiresAt) {
+			expireSubscription(ctx, deliveries, key, info)
+			continue
+		}
+
+		if pollDue(key, &info, now) {
+			requests = append(requests, info)
+		}
+	}
+
+	ids := []string{}
+	for _, info := range requests {
+		if len(info.SteamID) != 0 {
+			ids = append(ids, info.SteamID)
+		}
+	}
+
+	summaries := fetchSummaries(cycleCtx, ids)
+
+	scrapes := 0
+	failed := 0
+
+	slog.Debug("Starting update cycle", "subscriptions", len(queue), "due", len(requests))
+
+	results := dispatchScrapes(cycleCtx, source, requests, summaries, settings)
+	for results != nil {
+		select {
+		case result, ok := <-results:
+			if !ok {
+				results = nil
+				continue
+			}
+			scrapes++
+
+			if result.response.StatusCode != 200 {
+				failed++
+			}
+
+			if !processResult(ctx, deliveries, settings, result.info, result.response) {
+				cycleSpan.End()
+				return 0, false
+			}
+		case key := <-wakeups:
+			if !runWakeup(ctx, source, deliveries, key) {
+				cycleSpan.End()
+				return 0, false
+			}
+		}
+	}
+
+	cycleSpan.SetAttributes(attribute.Int("steam.due", len(requests)), attribute.Int("steam.scrapes", scrapes), attribute.Int("steam.failed_scrapes", failed))
+	cycleSpan.End()
+
+	if ctx.Err() != nil {
+		return 0, false
+	}
+
+	slog.Debug("Finished update cycle", "scrapes", scrapes, "failed", failed)
+
+	statsLock.Lock()
+	stats = cycleStats{
+		LastCycle:     currentClock.Now(),
+		Scrapes:       scrapes,
+		FailedScrapes: failed,
+	}
+	statsLock.Unlock()
+
+	return throttledInterval(jittered(settings.CycleInterval.Duration, settings.Jitter)), true
+}
+
+func envOrDefault(key string, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+
+	return fallback
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return value
+	}
+
+	return fallback
+}
+
+func defaultListenAddr() string {
+	if addr := os.Getenv("LISTEN_ADDR"); len(addr) != 0 {
+		return addr
+	}
+
+	if port := os.Getenv("PORT"); len(port) != 0 {
+		return ":" + port
+	}
+
+	return ":5555"
+}
+
+func main() {
+	timeout := flag.Int("status-timeout", 10, "timeout in seconds for on-demand status lookups")
+	eventsIdle := flag.Int("events-idle", 300, "seconds before an idle event stream is closed")
+	flag.StringVar(&adminToken, "admin-token", os.Getenv("ADMIN_TOKEN"), "bearer token required in the Authorization header for admin endpoints")
+	pprofAddr := flag.String("pprof-addr", os.Getenv("PPROF_ADDR"), "loopback address to serve pprof handlers on, disabled when empty")
+	flag.DurationVar(&pollDelay, "poll-delay", envDuration("POLL_DELAY", pollDelay), "delay between profile scrapes, overridden by the config file")
+	flag.DurationVar(&cycleInterval, "cycle-interval", envDuration("CYCLE_INTERVAL", cycleInterval), "pause between polling cycles, overridden by the config file")
+	flag.Float64Var(&jitter, "jitter", jitter, "random fraction of the poll delay and cycle interval added or subtracted each time")
+	logLevel := flag.String("log-level", envOrDefault("LOG_LEVEL", "info"), "minimum log level: debug, info, warn, or error")
+	logFormat := flag.String("log-format", envOrDefault("LOG_FORMAT", "text"), "log output format: text or json")
+	dbPath := flag.String("db", os.Getenv("DB_FILE"), "path to a SQLite database used to store subscriptions instead of memory")
+	redisURL := flag.String("redis", os.Getenv("REDIS_URL"), "redis:// URL of a Redis server used to share subscriptions between instances")
+	flag.StringVar(&stateFile, "state-file", os.Getenv("STATE_FILE"), "path to a JSON file that subscriptions are saved to and restored from")
+	flag.StringVar(&configPath, "config", os.Getenv("CONFIG_FILE"), "path to a JSON config file, reloaded on SIGHUP")
+	addr := flag.String("addr", defaultListenAddr(), "listen address for the HTTP server")
+	tlsCert := flag.String("tls-cert", "", "path to a TLS certificate, enables HTTPS together with -tls-key")
+	tlsKey := flag.String("tls-key", "", "path to the TLS private key")
+	tlsReload := flag.Bool("tls-reload", true, "reload the TLS certificate when the files change on disk")
+	origins := flag.String("cors-origins", envOrDefault("CORS_ORIGINS", "*"), "comma separated list of allowed CORS origins")
+	flag.BoolVar(&trustProxy, "trust-proxy", false, "use the last X-Forwarded-For hop, added by the trusted proxy, as the client address")
+	rateLimitValue := flag.Float64("rate-limit", 1, "requests per second allowed per client on lookup, wake, status and stream connections, 0 disables")
+	rateBurst := flag.Int("rate-burst", 10, "burst size for the per-client rate limit")
+	flag.StringVar(&apiKeysPath, "api-keys", os.Getenv("API_KEYS_FILE"), "path to a file of API keys required for registration, reloaded on SIGHUP")
+	flag.BoolVar(&allowPrivate, "allow-private", false, "allow pages and callbacks on private and loopback addresses")
+	flag.BoolVar(&requireHTTPSCallbacks, "require-https-callbacks", false, "reject callback urls that do not use https")
+	flag.StringVar(&steamAPIKey, "steam-api-key", os.Getenv("STEAM_API_KEY"), "Steam Web API key used to resolve vanity urls")
+	flag.IntVar(&missingThreshold, "missing-threshold", 5, "consecutive missing profile results before a subscription is removed")
+	flag.IntVar(&deliveryFailureThreshold, "delivery-failures", 5, "consecutive failed callback deliveries before a subscription is removed")
+	flag.IntVar(&deadLetterSize, "deadletter-size", 10, "failed deliveries kept per subscription for replay, 0 to disable")
+	flag.DurationVar(&deadLetterRetention, "deadletter-retention", 24*time.Hour, "how long failed deliveries are kept for replay")
+	flag.IntVar(&callbackBreakerFailures, "callback-breaker-failures", 5, "consecutive failed callbacks to a host before deliveries to it are paused, 0 to disable")
+	flag.DurationVar(&callbackBreakerCooldown, "callback-breaker-cooldown", time.Minute, "pause before a single delivery probes whether a callback host has recovered")
+	flag.IntVar(&maxSubscriptions, "max-subscriptions", 0, "maximum number of subscriptions, 0 for no limit, overridden by the config file")
+	flag.IntVar(&gzipMinBytes, "gzip-min-bytes", 1024, "minimum response size compressed for clients accepting gzip, 0 to disable")
+	flag.Int64Var(&maxBodyBytes, "max-body-bytes", 16*1024, "maximum request body size in bytes")
+	flag.IntVar(&deliveryWorkers, "delivery-workers", 4, "number of callbacks delivered concurrently")
+	callbackTimeout := flag.Duration("callback-timeout", 10*time.Second, "total timeout for a single callback request")
+	callbackDialTimeout := flag.Duration("callback-dial-timeout", 5*time.Second, "timeout for connecting to a callback host")
+	callbackTLSTimeout := flag.Duration("callback-tls-timeout", 5*time.Second, "timeout for the TLS handshake with a callback host")
+	flag.IntVar(&callbackAttempts, "callback-attempts", 3, "attempts per callback delivery for network errors and 5xx responses")
+	flag.DurationVar(&callbackRetryDelay, "callback-retry-delay", time.Second, "initial delay between callback attempts, doubled after each failure")
+	flag.IntVar(&scrapeAttempts, "scrape-attempts", 3, "attempts per scrape before giving up for the cycle")
+	flag.DurationVar(&scrapeRetryDelay, "scrape-retry-delay", time.Second, "initial delay between scrape attempts, doubled after each failure")
+	flag.StringVar(&probeURL, "probe-url", envOrDefault("PROBE_URL", "https://steamcommunity.com/robots.txt"), "Steam URL fetched to check whether Steam is reachable")
+	flag.DurationVar(&probeInterval, "probe-interval", envDuration("PROBE_INTERVAL", 3*time.Minute), "interval between Steam reachability probes, 0 to disable")
+	flag.IntVar(&scrapeBreaker.threshold, "breaker-failures", 5, "consecutive failed scrapes before scraping is paused, 0 to disable")
+	flag.DurationVar(&scrapeBreaker.cooldown, "breaker-cooldown", time.Minute, "pause before a single scrape probes whether Steam has recovered")
+	flag.DurationVar(&scrapeTimeout, "scrape-timeout", 10*time.Second, "timeout for a single profile scrape")
+	scrapeDialTimeout := flag.Duration("scrape-dial-timeout", 30*time.Second, "timeout for connecting to Steam or a proxy")
+	scrapeTLSTimeout := flag.Duration("scrape-tls-timeout", 10*time.Second, "timeout for the TLS handshake with Steam")
+	flag.IntVar(&maxIdleConnsPerHost, "http-max-idle-conns-per-host", 8, "idle keep-alive connections kept per host for outgoing requests")
+	flag.DurationVar(&idleConnTimeout, "http-idle-conn-timeout", 90*time.Second, "how long idle outgoing connections are kept open")
+	flag.IntVar(&scrapeMaxBytes, "scrape-max-bytes", 2*1024*1024, "maximum profile response size in bytes")
+	flag.StringVar(&userAgent, "user-agent", envOrDefault("USER_AGENT", defaultUserAgent()), "User-Agent header for requests to Steam")
+	userAgentsPath := flag.String("user-agents", os.Getenv("USER_AGENTS_FILE"), "path to a file of User-Agent strings to rotate through, one per line")
+	proxies := flag.String("proxy", os.Getenv("HTTPS_PROXY"), "comma-separated http, https or socks5 proxy URLs to rotate through for Steam requests")
+	flag.IntVar(&scrapeWorkers, "workers", 1, "number of profiles scraped concurrently")
+	grpcAddr := flag.String("grpc-addr", ":5556", "listen address for the gRPC server")
+	flag.DurationVar(&publishTimeout, "publish-timeout", 5*time.Second, "timeout for publishing a status change to a message transport")
+	mqttBroker := flag.String("mqtt-broker", os.Getenv("MQTT_BROKER"), "MQTT broker URL such as tcp://host:1883 or ssl://host:8883, enables the mqtt transport")
+	mqttUsername := flag.String("mqtt-username", os.Getenv("MQTT_USERNAME"), "username for the MQTT broker")
+	mqttPassword := flag.String("mqtt-password", os.Getenv("MQTT_PASSWORD"), "password for the MQTT broker")
+	mqttCA := flag.String("mqtt-ca", os.Getenv("MQTT_CA_FILE"), "path to a PEM CA bundle for verifying the MQTT broker")
+	mqttPrefix := flag.String("mqtt-topic-prefix", envOrDefault("MQTT_TOPIC_PREFIX", "steamstatus"), "prefix for MQTT status topics")
+	natsURL := flag.String("nats-url", os.Getenv("NATS_URL"), "NATS server URL such as nats://host:4222, enables the nats transport")
+	natsStream := flag.String("nats-stream", os.Getenv("NATS_STREAM"), "JetStream stream expected to capture status subjects, publishes wait for acks when set")
+	natsPrefix := flag.String("nats-subject-prefix", envOrDefault("NATS_SUBJECT_PREFIX", "steam.status"), "prefix for NATS status subjects")
+	natsBuffer := flag.Int("nats-buffer", 1000, "status messages buffered while disconnected from NATS, oldest dropped first")
+	flag.Parse()
+
+	if err := setupLogging(*logLevel, *logFormat); err != nil {
+		log.Fatal(err)
+	}
+
+	closeMQTT := func() {}
+	if len(*mqttBroker) != 0 {
+		var err error
+		closeMQTT, err = setupMQTT(*mqttBroker, *mqttUsername, *mqttPassword, *mqttCA, *mqttPrefix)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	closeNATS := func() {}
+	if len(*natsURL) != 0 {
+		var err error
+		closeNATS, err = setupNATS(*natsURL, *natsStream, *natsPrefix, *natsBuffer)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if scrapeWorkers < 1 {
+		scrapeWorkers = 1
+	}
+
+	if deliveryWorkers < 1 {
+		deliveryWorkers = 1
+	}
+
+	corsOrigins = parseOrigins(*origins)
+
+	if *rateLimitValue > 0 {
+		limiter = newIPRateLimiter(*rateLimitValue, *rateBurst)
+	}
+
+	settings, err := loadConfig(configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	currentConfig.Store(settings)
+
+	if len(*userAgentsPath) != 0 {
+		agents, err := loadLines(*userAgentsPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		userAgents = agents
+	}
+
+	if len(apiKeysPath) != 0 {
+		keys, err := loadLines(apiKeysPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		apiKeys.Store(&keys)
+	}
+	go watchConfig()
+
+	startTime = time.Now()
+	callbackOptions := transportOptions{
+		DialTimeout:         *callbackDialTimeout,
+		TLSTimeout:          *callbackTLSTimeout,
+		MaxIdleConnsPerHost: maxIdleConnsPerHost,
+		IdleConnTimeout:     idleConnTimeout,
+		Wrap:                transportHook,
+	}
+	callbackTransport := newTransport(callbackOptions)
+	callbackTransport.Proxy = nil
+	callbackClient = newHTTPClient(callbackOptions.wrap(callbackTransport), *callbackTimeout)
+
+	scrapeOptions := transportOptions{
+		DialTimeout:         *scrapeDialTimeout,
+		TLSTimeout:          *scrapeTLSTimeout,
+		MaxIdleConnsPerHost: maxIdleConnsPerHost,
+		IdleConnTimeout:     idleConnTimeout,
+		Wrap:                transportHook,
+	}
+	steamTransport, err := newScrapeTransport(scrapeOptions, parseProxies(*proxies))
+	if err != nil {
+		log.Fatal(err)
+	}
+	scrapeTransport := scrapeOptions.wrap(&userAgentTransport{base: steamTransport})
+	steamClient = newHTTPClient(scrapeTransport, 10*time.Second)
+	pageScraper = newCollyScraper(scrapeTransport)
+	statusTimeout = time.Duration(*timeout) * time.Second
+	eventsIdleTimeout = time.Duration(*eventsIdle) * time.Second
+	backend, err := openStore(*redisURL, *dbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := rekeyStore(backend); err != nil {
+		log.Fatal(err)
+	}
+	subscriptions = backend
+	subscriptionStates = make(map[string]subscriptionState)
+	go runCacheSweep(10 * time.Minute)
+
+	if len(stateFile) != 0 {
+		loadState()
+	}
+
+	if len(*pprofAddr) != 0 {
+		if err := startProfiler(*pprofAddr); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if limiter != nil {
+		go limiter.runEviction(ctx, 10*time.Minute)
+	}
+
+	shutdownTracing, err := setupTracing(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(stateFile) != 0 {
+		go runStateWriter(ctx, 2*time.Second)
+	}
+
+	if probeInterval > 0 {
+		go runProbe(ctx, newHTTPClient(scrapeTransport, 0))
+	}
+
+	updateDone := make(chan struct{})
+	go func() {
+		runUpdate(ctx, pageScraper, callbackClient)
+		close(updateDone)
+	}()
+
+	listener, err := net.Listen("tcp", *grpcAddr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	rpcServer := grpc.NewServer()
+	pb.RegisterSteamStatusServer(rpcServer, &grpcServer{})
+	go rpcServer.Serve(listener)
+
+	httpListener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatalf("Unable to listen on %s: %v", *addr, err)
+	}
+
+	server := &http.Server{Handler: loggingMiddleware(corsMiddleware(gzipMiddleware(newAPIMux())))}
+
+	if len(*tlsCert) != 0 || len(*tlsKey) != 0 {
+		reloader, err := newCertReloader(*tlsCert, *tlsKey, *tlsReload)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		server.TLSConfig = &tls.Config{GetCertificate: reloader.GetCertificate}
+	}
+
+	serverError := make(chan error, 1)
+	go func() {
+		if server.TLSConfig != nil {
+			serverError <- server.ServeTLS(httpListener, "", "")
+		} else {
+			serverError <- server.Serve(httpListener)
+		}
+	}()
+
+	slog.Info("Server is now running", "addr", httpListener.Addr().String())
+
+	select {
+	case err := <-serverError:
+		log.Fatal(err)
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down")
+	draining.Store(true)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	select {
+	case <-updateDone:
+	case <-shutdownCtx.Done():
+		slog.Warn("Update loop did not stop before the deadline")
+	}
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Failed to shut down server", "err", err)
+	}
+	rpcServer.GracefulStop()
+	closeMQTT()
+	closeNATS()
+
+	if len(stateFile) != 0 {
+		if err := saveState(); err != nil {
+			slog.Error("Failed to save state", "err", err)
+		}
+	}
+
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Failed to flush traces", "err", err)
+	}
 
-	log.Println("Server is now running...")
-	log.Fatal(http.ListenAndServe(":5555", nil))
+	slog.Info("Server stopped")
 }
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeScraper struct {
+	lock      sync.Mutex
+	responses []*statusInfo
+	pages     []string
+}
+
+func (f *fakeScraper) Scrape(ctx context.Context, page string) (*statusInfo, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.pages = append(f.pages, page)
+	response := *f.responses[0]
+	if len(f.responses) > 1 {
+		f.responses = f.responses[1:]
+	}
+
+	return &response, nil
+}
+
+func (f *fakeScraper) scraped() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return append([]string{}, f.pages...)
+}
+
+func setupTest(t *testing.T) {
+	t.Helper()
+
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	subscriptions = newMemoryStore()
+	subscriptionStates = make(map[string]subscriptionState)
+	hub = newStatusHub()
+	currentConfig.Store(&config{CycleInterval: duration{time.Minute}})
+	scrapeWorkers = 1
+	scrapeAttempts = 1
+	scrapeTimeout = 2 * time.Second
+	scrapeRetryDelay = 10 * time.Millisecond
+	missingThreshold = 3
+	scrapePacer.next = time.Time{}
+	maxBodyBytes = 16 * 1024
+	deliveryFailureThreshold = 3
+	deliveryWorkers = 1
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	scrapesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "steam_status_scrapes_total",
+		Help: "Profile scrapes by resulting status code.",
+	}, []string{"code"})
+
+	callbacksTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "steam_status_callbacks_total",
+		Help: "Callback deliveries by outcome.",
+	}, []string{"outcome"})
+
+	subscriptionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "steam_status_subscriptions_total",
+		Help: "Subscriptions added and removed.",
+	}, []string{"action"})
+
+	rejectedSubscriptionsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "steam_status_subscriptions_rejected_total",
+		Help: "Registrations rejected because the subscription limit was reached.",
+	})
+
+	natsDroppedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "steam_status_nats_dropped_total",
+		Help: "Status messages dropped because the NATS reconnect buffer was full.",
+	})
+
+	wakesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "steam_status_wakes_total",
+		Help: "Accepted wake requests.",
+	})
+
+	scrapeDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "steam_status_scrape_duration_seconds",
+		Help:    "Duration of profile scrapes.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	callbackDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "steam_status_callback_duration_seconds",
+		Help:    "Latency of callback requests.",
+		Buckets: prometheus.DefBuckets,
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		scrapesTotal,
+		callbacksTotal,
+		subscriptionsTotal,
+		rejectedSubscriptionsTotal,
+		natsDroppedTotal,
+		wakesTotal,
+		scrapeDuration,
+		callbackDuration,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "steam_reachable",
+			Help: "Whether the last Steam probe succeeded.",
+		}, func() float64 {
+			if result, ok := currentProbe(); ok && !result.Reachable {
+				return 0
+			}
+			return 1
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "steam_probe_latency_seconds",
+			Help: "Latency of the last Steam probe.",
+		}, func() float64 {
+			result, _ := currentProbe()
+			return result.Latency.Seconds()
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "steam_status_scrape_breaker_state",
+			Help: "State of the scrape circuit breaker: 0 closed, 1 half-open, 2 open.",
+		}, func() float64 {
+			return float64(scrapeBreaker.current())
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "steam_status_request_queue_size",
+			Help: "Number of entries in the request queue.",
+		}, func() float64 {
+			queueSize, _ := storeSizes()
+			return float64(queueSize)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "steam_status_status_cache_size",
+			Help: "Number of entries in the status cache.",
+		}, func() float64 {
+			_, cacheSize := storeSizes()
+			return float64(cacheSize)
+		}),
+	)
+}
+
+func recordScrape(code int, started time.Time) {
+	scrapesTotal.WithLabelValues(strconv.Itoa(code)).Inc()
+	scrapeDuration.Observe(time.Since(started).Seconds())
+}
+
+func recordCallback(outcome string, started time.Time) {
+	callbacksTotal.WithLabelValues(outcome).Inc()
+	callbackDuration.Observe(time.Since(started).Seconds())
+}
+
+func recordSubscription(action string) {
+	subscriptionsTotal.WithLabelValues(action).Inc()
+}
+
+func recordRejectedSubscription() {
+	rejectedSubscriptionsTotal.Inc()
+}
+
+func recordNATSDropped(count int) {
+	natsDroppedTotal.Add(float64(count))
+}
+
+func recordWake() {
+	wakesTotal.Inc()
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+var corsOrigins []string
+var trustProxy bool
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(data []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+
+	size, err := s.ResponseWriter.Write(data)
+	s.size += size
+	return size, err
+}
+
+func (s *statusRecorder) Flush() {
+	if flusher, ok := s.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+
+	if s.status == 0 {
+		s.status = http.StatusSwitchingProtocols
+	}
+
+	return hijacker.Hijack()
+}
+
+func clientIP(r *http.Request) string {
+	if trustProxy {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) != 0 {
+			hops := strings.Split(forwarded[len(forwarded)-1], ",")
+			if hop := strings.TrimSpace(hops[len(hops)-1]); len(hop) != 0 {
+				return hop
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+func loggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(recorder, r)
+
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+
+		slog.Info("Request", "method", r.Method, "path", r.URL.Path, "client", clientIP(r), "status", recorder.status, "size", recorder.size, "duration", time.Since(started))
+	})
+}
+
+func parseOrigins(value string) []string {
+	origins := []string{}
+
+	for _, origin := range strings.Split(value, ",") {
+		origin = strings.TrimSpace(origin)
+		if len(origin) != 0 {
+			origins = append(origins, strings.TrimSuffix(origin, "/"))
+		}
+	}
+
+	return origins
+}
+
+func allowedOrigin(origin string) string {
+	for _, allowed := range corsOrigins {
+		if allowed == "*" {
+			return "*"
+		}
+
+		if strings.EqualFold(allowed, origin) {
+			return origin
+		}
+	}
+
+	return ""
+}
+
+func corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+
+		if len(origin) != 0 {
+			w.Header().Add("Vary", "Origin")
+
+			if allowed := allowedOrigin(origin); len(allowed) != 0 {
+				w.Header().Set("Access-Control-Allow-Origin", allowed)
+
+				if r.Method == http.MethodOptions && len(r.Header.Get("Access-Control-Request-Method")) != 0 {
+					headers := r.Header.Get("Access-Control-Request-Headers")
+					if len(headers) == 0 {
+						headers = "Content-Type, Authorization, API-Token"
+					}
+
+					w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+					w.Header().Set("Access-Control-Allow-Headers", headers)
+					w.Header().Set("Access-Control-Max-Age", "600")
+				}
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+type mqttPublisher struct {
+	client mqtt.Client
+	prefix string
+}
+
+func (m *mqttPublisher) topic(info *requestInfo) string {
+	return m.prefix + "/" + profileIdentifier(info) + "/state"
+}
+
+func (m *mqttPublisher) publish(ctx context.Context, info *requestInfo, payload []byte) error {
+	token := m.client.Publish(m.topic(info), 1, true, payload)
+
+	select {
+	case <-token.Done():
+		return token.Error()
+	case <-ctx.Done():
+		return errors.New("mqtt publish timed out")
+	}
+}
+
+func mqttTLSConfig(broker string, caPath string) (*tls.Config, error) {
+	scheme := strings.ToLower(strings.SplitN(broker, "://", 2)[0])
+	if scheme != "ssl" && scheme != "tls" && scheme != "mqtts" && len(caPath) == 0 {
+		return nil, nil
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(caPath) == 0 {
+		return config, nil
+	}
+
+	pem, err := os.ReadFile(caPath)
+	if err != nil {
+		return nil, err
+	}
+
+	config.RootCAs = x509.NewCertPool()
+	if !config.RootCAs.AppendCertsFromPEM(pem) {
+		return nil, errors.New("no certificates found in " + caPath)
+	}
+
+	return config, nil
+}
+
+func setupMQTT(broker string, username string, password string, caPath string, prefix string) (func(), error) {
+	tlsConfig, err := mqttTLSConfig(broker, caPath)
+	if err != nil {
+		return nil, err
+	}
+
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+
+	options := mqtt.NewClientOptions().
+		AddBroker(broker).
+		SetClientID("steam-status-" + hex.EncodeToString(suffix)).
+		SetUsername(username).
+		SetPassword(password).
+		SetTLSConfig(tlsConfig).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(5 * time.Second).
+		SetMaxReconnectInterval(2 * time.Minute).
+		SetOnConnectHandler(func(mqtt.Client) {
+			slog.Info("Connected to MQTT broker", "broker", pageHost(broker))
+		}).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			slog.Warn("Lost connection to MQTT broker", "broker", pageHost(broker), "err", err)
+		})
+
+	client := mqtt.NewClient(options)
+	client.Connect()
+
+	publishers["mqtt"] = &mqttPublisher{client: client, prefix: strings.TrimSuffix(prefix, "/")}
+
+	return func() {
+		client.Disconnect(250)
+	}, nil
+}
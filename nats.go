@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+type natsMessage struct {
+	subject string
+	payload []byte
+}
+
+type natsPublisher struct {
+	conn    *nats.Conn
+	js      nats.JetStreamContext
+	stream  string
+	prefix  string
+	limit   int
+	pending []natsMessage
+	lock    sync.Mutex
+}
+
+func natsSubject(prefix string, info *requestInfo) string {
+	token := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, profileIdentifier(info))
+
+	return prefix + "." + token
+}
+
+func (n *natsPublisher) send(ctx context.Context, message natsMessage) error {
+	if n.js == nil {
+		return n.conn.Publish(message.subject, message.payload)
+	}
+
+	_, err := n.js.Publish(message.subject, message.payload, nats.Context(ctx), nats.ExpectStream(n.stream))
+	return err
+}
+
+func (n *natsPublisher) buffer(message natsMessage) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.pending = append(n.pending, message)
+	if len(n.pending) > n.limit {
+		dropped := len(n.pending) - n.limit
+		n.pending = n.pending[dropped:]
+		recordNATSDropped(dropped)
+	}
+}
+
+func (n *natsPublisher) publish(ctx context.Context, info *requestInfo, payload []byte) error {
+	message := natsMessage{natsSubject(n.prefix, info), payload}
+
+	if !n.conn.IsConnected() && n.limit > 0 {
+		n.buffer(message)
+		return nil
+	}
+
+	err := n.send(ctx, message)
+	if err != nil && !n.conn.IsConnected() && n.limit > 0 {
+		n.buffer(message)
+		return nil
+	}
+
+	return err
+}
+
+func (n *natsPublisher) flush() {
+	n.lock.Lock()
+	pending := n.pending
+	n.pending = nil
+	n.lock.Unlock()
+
+	for i, message := range pending {
+		ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+		err := n.send(ctx, message)
+		cancel()
+
+		if err != nil {
+			slog.Warn("Failed to flush buffered NATS messages", "remaining", len(pending)-i, "err", err)
+			for _, rest := range pending[i:] {
+				n.buffer(rest)
+			}
+			return
+		}
+	}
+
+	if len(pending) != 0 {
+		slog.Info("Flushed buffered NATS messages", "count", len(pending))
+	}
+}
+
+func setupNATS(url string, stream string, prefix string, limit int) (func(), error) {
+	publisher := &natsPublisher{stream: stream, prefix: strings.TrimSuffix(prefix, "."), limit: limit}
+
+	conn, err := nats.Connect(url,
+		nats.Name("steam-status"),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(2*time.Second),
+		nats.ReconnectJitter(time.Second, time.Second),
+		nats.ReconnectBufSize(-1),
+		nats.ConnectHandler(func(*nats.Conn) {
+			slog.Info("Connected to NATS", "server", pageHost(url))
+			go publisher.flush()
+		}),
+		nats.ReconnectHandler(func(*nats.Conn) {
+			slog.Info("Reconnected to NATS", "server", pageHost(url))
+			go publisher.flush()
+		}),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			slog.Warn("Lost connection to NATS", "server", pageHost(url), "err", err)
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	publisher.conn = conn
+
+	if len(stream) != 0 {
+		publisher.js, err = conn.JetStream()
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	publishers["nats"] = publisher
+
+	return func() {
+		conn.Drain()
+	}, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func requireJSON(w http.ResponseWriter, r *http.Request) bool {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err == nil && mediaType == "application/json" {
+		if charset, ok := params["charset"]; !ok || strings.EqualFold(charset, "utf-8") {
+			return true
+		}
+	}
+
+	writeError(w, http.StatusUnsupportedMediaType, codeUnsupportedMediaType, "Content-Type must be application/json")
+	return false
+}
+
+func acceptQuality(accept string, mediaType string) float64 {
+	major := strings.SplitN(mediaType, "/", 2)[0]
+	quality, specificity := 0.0, -1
+
+	for _, part := range strings.Split(accept, ",") {
+		candidate, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		rank := -1
+		switch candidate {
+		case mediaType:
+			rank = 2
+		case major + "/*":
+			rank = 1
+		case "*/*":
+			rank = 0
+		}
+
+		if rank <= specificity {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		quality, specificity = q, rank
+	}
+
+	return quality
+}
+
+func prefersText(w http.ResponseWriter, r *http.Request) bool {
+	w.Header().Add("Vary", "Accept")
+
+	accept := r.Header.Get("Accept")
+	if len(accept) == 0 {
+		return false
+	}
+
+	return acceptQuality(accept, "text/plain") > acceptQuality(accept, "application/json")
+}
+
+func writeText(w http.ResponseWriter, status int, text string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write([]byte(text))
+}
+
+func statusText(page string, s *statusInfo) string {
+	game := "-"
+	if s.IsPlaying {
+		game = s.GameName
+	}
+
+	return fmt.Sprintf("page: %s\npersona: %s\nstate: %s\nplaying: %t\ngame: %s\n", page, s.PersonaName, s.OnlineState, s.IsPlaying, game)
+}
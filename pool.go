@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+type scrapeJob struct {
+	info requestInfo
+}
+
+type scrapeResult struct {
+	scrapeJob
+	response *statusInfo
+	fromAPI  bool
+}
+
+type pacer struct {
+	lock sync.Mutex
+	next time.Time
+}
+
+var scrapeWorkers int
+var scrapePacer pacer
+
+func jittered(d time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 {
+		return d
+	}
+
+	return d + time.Duration((rand.Float64()*2-1)*fraction*float64(d))
+}
+
+func (p *pacer) wait(ctx context.Context, delay time.Duration) bool {
+	p.lock.Lock()
+	now := currentClock.Now()
+	at := p.next
+	if at.Before(now) {
+		at = now
+	}
+	p.next = at.Add(delay)
+	p.lock.Unlock()
+
+	return sleepContext(ctx, at.Sub(now))
+}
+
+func scrapeWorker(ctx context.Context, source scraper, jobs <-chan scrapeJob, results chan<- scrapeResult, summaries map[string]*statusInfo, settings *config) {
+	for job := range jobs {
+		result := scrapeResult{scrapeJob: job}
+
+		if len(job.info.SteamID) != 0 {
+			result.response, result.fromAPI = summaries[job.info.SteamID]
+		}
+
+		if !result.fromAPI {
+			if !scrapeBreaker.allow() {
+				continue
+			}
+
+			if !waitThrottle(ctx) || !scrapePacer.wait(ctx, jittered(settings.PollDelay.Duration, settings.Jitter)) {
+				return
+			}
+			result.response = gatherStatus(ctx, source, job.info.Page)
+			scrapeBreaker.record(!retryableStatus(result.response.StatusCode))
+		}
+
+		select {
+		case results <- result:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func dispatchScrapes(ctx context.Context, source scraper, requests []requestInfo, summaries map[string]*statusInfo, settings *config) <-chan scrapeResult {
+	jobs := make(chan scrapeJob)
+	results := make(chan scrapeResult)
+
+	go func() {
+		defer close(jobs)
+
+		for _, info := range requests {
+			select {
+			case jobs <- scrapeJob{info: info}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	workers := sync.WaitGroup{}
+	for i := 0; i < scrapeWorkers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			scrapeWorker(ctx, source, jobs, results, summaries, settings)
+		}()
+	}
+
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
+
+	return results
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJitteredStaysWithinBounds(t *testing.T) {
+	base := 30 * time.Second
+
+	for _, fraction := range []float64{0.05, 0.1, 0.5} {
+		low := time.Duration(float64(base) * (1 - fraction))
+		high := time.Duration(float64(base) * (1 + fraction))
+		spread := false
+
+		for i := 0; i < 1000; i++ {
+			d := jittered(base, fraction)
+			if d < low || d > high {
+				t.Fatalf("jittered(%v, %v) = %v, outside [%v, %v]", base, fraction, d, low, high)
+			}
+			spread = spread || d != base
+		}
+
+		if !spread {
+			t.Fatalf("jittered(%v, %v) never varied", base, fraction)
+		}
+	}
+
+	if d := jittered(base, 0); d != base {
+		t.Fatalf("jittered without a fraction = %v", d)
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIDoesNotServeProfiler(t *testing.T) {
+	setupTest(t)
+
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/cmdline"} {
+		recorder := httptest.NewRecorder()
+		newAPIMux().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("%s returned %d, want %d", path, recorder.Code, http.StatusNotFound)
+		}
+	}
+}
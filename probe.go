@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type probeResult struct {
+	Reachable bool      `json:"reachable"`
+	Latency   duration  `json:"latency"`
+	CheckedAt time.Time `json:"checkedAt"`
+	Error     string    `json:"error,omitempty"`
+}
+
+var probeURL string
+var probeInterval time.Duration
+var lastProbe probeResult
+var probeLock sync.Mutex
+
+func probeSteam(ctx context.Context, client *http.Client) probeResult {
+	ctx, cancel := context.WithTimeout(ctx, scrapeTimeout)
+	defer cancel()
+
+	started := time.Now()
+	result := probeResult{CheckedAt: started}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, probeURL, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	response, err := client.Do(req)
+	result.Latency = duration{time.Since(started)}
+	if err != nil {
+		result.Error = scrapeError(err)
+		return result
+	}
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024))
+	response.Body.Close()
+
+	result.Reachable = response.StatusCode < 500
+	if !result.Reachable {
+		result.Error = response.Status
+	}
+
+	return result
+}
+
+func runProbe(ctx context.Context, client *http.Client) {
+	for {
+		result := probeSteam(ctx, client)
+		if ctx.Err() != nil {
+			return
+		}
+
+		probeLock.Lock()
+		changed := lastProbe.CheckedAt.IsZero() || lastProbe.Reachable != result.Reachable
+		lastProbe = result
+		probeLock.Unlock()
+
+		if changed {
+			slog.Info("Steam reachability changed", "reachable", result.Reachable, "latency", result.Latency.Duration, "err", result.Error)
+		}
+
+		if !sleepContext(ctx, probeInterval) {
+			return
+		}
+	}
+}
+
+func currentProbe() (probeResult, bool) {
+	probeLock.Lock()
+	defer probeLock.Unlock()
+	return lastProbe, !lastProbe.CheckedAt.IsZero()
+}
+
+func steamDown() bool {
+	result, ok := currentProbe()
+	return ok && !result.Reachable
+}
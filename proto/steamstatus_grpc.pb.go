@@ -0,0 +1,248 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: steamstatus.proto
+
+package steamstatuspb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	SteamStatus_Subscribe_FullMethodName   = "/steamstatus.SteamStatus/Subscribe"
+	SteamStatus_Unsubscribe_FullMethodName = "/steamstatus.SteamStatus/Unsubscribe"
+	SteamStatus_GetStatus_FullMethodName   = "/steamstatus.SteamStatus/GetStatus"
+	SteamStatus_WatchStatus_FullMethodName = "/steamstatus.SteamStatus/WatchStatus"
+)
+
+// SteamStatusClient is the client API for SteamStatus service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SteamStatusClient interface {
+	Subscribe(ctx context.Context, in *RequestInfo, opts ...grpc.CallOption) (*SubscribeResponse, error)
+	Unsubscribe(ctx context.Context, in *UnsubscribeRequest, opts ...grpc.CallOption) (*SubscribeResponse, error)
+	GetStatus(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusInfo, error)
+	WatchStatus(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (SteamStatus_WatchStatusClient, error)
+}
+
+type steamStatusClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSteamStatusClient(cc grpc.ClientConnInterface) SteamStatusClient {
+	return &steamStatusClient{cc}
+}
+
+func (c *steamStatusClient) Subscribe(ctx context.Context, in *RequestInfo, opts ...grpc.CallOption) (*SubscribeResponse, error) {
+	out := new(SubscribeResponse)
+	err := c.cc.Invoke(ctx, SteamStatus_Subscribe_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *steamStatusClient) Unsubscribe(ctx context.Context, in *UnsubscribeRequest, opts ...grpc.CallOption) (*SubscribeResponse, error) {
+	out := new(SubscribeResponse)
+	err := c.cc.Invoke(ctx, SteamStatus_Unsubscribe_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *steamStatusClient) GetStatus(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusInfo, error) {
+	out := new(StatusInfo)
+	err := c.cc.Invoke(ctx, SteamStatus_GetStatus_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *steamStatusClient) WatchStatus(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (SteamStatus_WatchStatusClient, error) {
+	stream, err := c.cc.NewStream(ctx, &SteamStatus_ServiceDesc.Streams[0], SteamStatus_WatchStatus_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &steamStatusWatchStatusClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type SteamStatus_WatchStatusClient interface {
+	Recv() (*StatusInfo, error)
+	grpc.ClientStream
+}
+
+type steamStatusWatchStatusClient struct {
+	grpc.ClientStream
+}
+
+func (x *steamStatusWatchStatusClient) Recv() (*StatusInfo, error) {
+	m := new(StatusInfo)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SteamStatusServer is the server API for SteamStatus service.
+// All implementations must embed UnimplementedSteamStatusServer
+// for forward compatibility
+type SteamStatusServer interface {
+	Subscribe(context.Context, *RequestInfo) (*SubscribeResponse, error)
+	Unsubscribe(context.Context, *UnsubscribeRequest) (*SubscribeResponse, error)
+	GetStatus(context.Context, *StatusRequest) (*StatusInfo, error)
+	WatchStatus(*StatusRequest, SteamStatus_WatchStatusServer) error
+	mustEmbedUnimplementedSteamStatusServer()
+}
+
+// UnimplementedSteamStatusServer must be embedded to have forward compatible implementations.
+type UnimplementedSteamStatusServer struct {
+}
+
+func (UnimplementedSteamStatusServer) Subscribe(context.Context, *RequestInfo) (*SubscribeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedSteamStatusServer) Unsubscribe(context.Context, *UnsubscribeRequest) (*SubscribeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Unsubscribe not implemented")
+}
+func (UnimplementedSteamStatusServer) GetStatus(context.Context, *StatusRequest) (*StatusInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStatus not implemented")
+}
+func (UnimplementedSteamStatusServer) WatchStatus(*StatusRequest, SteamStatus_WatchStatusServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchStatus not implemented")
+}
+func (UnimplementedSteamStatusServer) mustEmbedUnimplementedSteamStatusServer() {}
+
+// UnsafeSteamStatusServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SteamStatusServer will
+// result in compilation errors.
+type UnsafeSteamStatusServer interface {
+	mustEmbedUnimplementedSteamStatusServer()
+}
+
+func RegisterSteamStatusServer(s grpc.ServiceRegistrar, srv SteamStatusServer) {
+	s.RegisterService(&SteamStatus_ServiceDesc, srv)
+}
+
+func _SteamStatus_Subscribe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestInfo)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SteamStatusServer).Subscribe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SteamStatus_Subscribe_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SteamStatusServer).Subscribe(ctx, req.(*RequestInfo))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SteamStatus_Unsubscribe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnsubscribeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SteamStatusServer).Unsubscribe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SteamStatus_Unsubscribe_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SteamStatusServer).Unsubscribe(ctx, req.(*UnsubscribeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SteamStatus_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SteamStatusServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SteamStatus_GetStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SteamStatusServer).GetStatus(ctx, req.(*StatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SteamStatus_WatchStatus_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StatusRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SteamStatusServer).WatchStatus(m, &steamStatusWatchStatusServer{stream})
+}
+
+type SteamStatus_WatchStatusServer interface {
+	Send(*StatusInfo) error
+	grpc.ServerStream
+}
+
+type steamStatusWatchStatusServer struct {
+	grpc.ServerStream
+}
+
+func (x *steamStatusWatchStatusServer) Send(m *StatusInfo) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// SteamStatus_ServiceDesc is the grpc.ServiceDesc for SteamStatus service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SteamStatus_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "steamstatus.SteamStatus",
+	HandlerType: (*SteamStatusServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Subscribe",
+			Handler:    _SteamStatus_Subscribe_Handler,
+		},
+		{
+			MethodName: "Unsubscribe",
+			Handler:    _SteamStatus_Unsubscribe_Handler,
+		},
+		{
+			MethodName: "GetStatus",
+			Handler:    _SteamStatus_GetStatus_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchStatus",
+			Handler:       _SteamStatus_WatchStatus_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "steamstatus.proto",
+}
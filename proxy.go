@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gocolly/colly/proxy"
+)
+
+func parseProxies(raw string) []string {
+	proxies := []string{}
+	for _, entry := range strings.Split(raw, ",") {
+		if entry = strings.TrimSpace(entry); len(entry) != 0 {
+			proxies = append(proxies, entry)
+		}
+	}
+
+	return proxies
+}
+
+var defaultProxyPorts = map[string]string{
+	"http":   "80",
+	"https":  "443",
+	"socks5": "1080",
+}
+
+func proxyAddresses(proxies []string) map[string]bool {
+	addresses := make(map[string]bool)
+	for _, raw := range proxies {
+		parsed, err := url.Parse(raw)
+		if err != nil || len(parsed.Hostname()) == 0 {
+			continue
+		}
+
+		port := parsed.Port()
+		if len(port) == 0 {
+			port = defaultProxyPorts[strings.ToLower(parsed.Scheme)]
+		}
+		addresses[net.JoinHostPort(parsed.Hostname(), port)] = true
+	}
+
+	return addresses
+}
+
+func newScrapeTransport(options transportOptions, proxies []string) (*http.Transport, error) {
+	transport := newTransport(options)
+	if len(proxies) == 0 {
+		return transport, nil
+	}
+
+	switcher, err := proxy.RoundRobinProxySwitcher(proxies...)
+	if err != nil {
+		return nil, err
+	}
+
+	exempt := proxyAddresses(proxies)
+	safeDial := transport.DialContext
+	direct := &net.Dialer{Timeout: options.DialTimeout, KeepAlive: 30 * time.Second}
+	transport.DialContext = func(ctx context.Context, network string, address string) (net.Conn, error) {
+		if exempt[address] {
+			return direct.DialContext(ctx, network, address)
+		}
+		return safeDial(ctx, network, address)
+	}
+
+	transport.Proxy = switcher
+	return transport, nil
+}
+
+func isProxyError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "proxyconnect"
+}
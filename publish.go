@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type publisher interface {
+	publish(ctx context.Context, info *requestInfo, payload []byte) error
+}
+
+var publishers = make(map[string]publisher)
+var publishTimeout time.Duration
+
+func publishTransport(transport string) bool {
+	_, ok := publishers[transport]
+	return ok
+}
+
+func publishPayload(ctx context.Context, info *requestInfo, payload []byte) error {
+	target, ok := publishers[info.Transport]
+	if !ok {
+		return errors.New("transport " + info.Transport + " is not configured")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, publishTimeout)
+	defer cancel()
+
+	return target.publish(ctx, info, payload)
+}
+
+func profileIdentifier(info *requestInfo) string {
+	if len(info.SteamID) != 0 {
+		return info.SteamID
+	}
+
+	return strings.Trim(strings.TrimPrefix(info.Page, "https://steamcommunity.com/id/"), "/")
+}
+
+func publishStatus(ctx context.Context, job deliveryJob) {
+	if job.expired {
+		return
+	}
+
+	started := time.Now()
+	err := publishPayload(ctx, &job.info, []byte(callbackJSON(&job.info, job.response)))
+	if err != nil {
+		recordCallback("error", started)
+		recordDeliveryResult(job.key, "error")
+		recordDeadLetter(job, 1, err.Error())
+		slog.Warn("Failed to publish status", append(subscriptionAttrs(job.key, &job.info), "transport", job.info.Transport, "err", err)...)
+		return
+	}
+
+	recordCallback("success", started)
+	recordDeliveryResult(job.key, "success")
+}
+
+func deliver(ctx context.Context, client *http.Client, job deliveryJob) {
+	switch {
+	case len(job.info.Transport) != 0:
+		publishStatus(ctx, job)
+	case job.expired:
+		deliverExpired(client, job)
+	default:
+		deliverStatus(ctx, client, job)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type visitor struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+type ipRateLimiter struct {
+	lock     sync.Mutex
+	visitors map[string]*visitor
+	limit    float64
+	burst    int
+}
+
+var limiter *ipRateLimiter
+
+func newIPRateLimiter(limit float64, burst int) *ipRateLimiter {
+	return &ipRateLimiter{
+		visitors: make(map[string]*visitor),
+		limit:    limit,
+		burst:    burst,
+	}
+}
+
+func (l *ipRateLimiter) reserve(ip string) time.Duration {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := currentClock.Now()
+
+	entry, ok := l.visitors[ip]
+	if !ok {
+		entry = &visitor{tokens: float64(l.burst), lastSeen: now}
+		l.visitors[ip] = entry
+	}
+
+	entry.tokens = math.Min(float64(l.burst), entry.tokens+now.Sub(entry.lastSeen).Seconds()*l.limit)
+	entry.lastSeen = now
+
+	if entry.tokens < 1 {
+		return time.Duration((1 - entry.tokens) / l.limit * float64(time.Second))
+	}
+
+	entry.tokens--
+	return 0
+}
+
+func (l *ipRateLimiter) evict(now time.Time, idle time.Duration) {
+	l.lock.Lock()
+	for ip, entry := range l.visitors {
+		if now.Sub(entry.lastSeen) > idle {
+			delete(l.visitors, ip)
+		}
+	}
+	l.lock.Unlock()
+}
+
+func (l *ipRateLimiter) runEviction(ctx context.Context, interval time.Duration) {
+	ticker := currentClock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.Chan():
+			l.evict(now, interval)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func rateLimit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if limiter != nil {
+			if delay := limiter.reserve(clientIP(r)); delay > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+				writeError(w, http.StatusTooManyRequests, codeRateLimited, "rate limit exceeded")
+				return
+			}
+		}
+
+		next(w, r)
+	}
+}
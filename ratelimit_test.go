@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterEviction(t *testing.T) {
+	clock := useFakeClock(t)
+	l := newIPRateLimiter(1, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		l.runEviction(ctx, 10*time.Minute)
+		close(done)
+	}()
+	waitFor(t, func() bool { return clock.pending() == 1 })
+
+	l.reserve("192.0.2.1")
+	clock.Advance(20 * time.Minute)
+	waitFor(t, func() bool {
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		return len(l.visitors) == 0
+	})
+
+	cancel()
+	<-done
+	if clock.pending() != 0 {
+		t.Fatal("ticker kept after eviction stopped")
+	}
+}
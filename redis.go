@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type redisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+var swapStatusScript = redis.NewScript(`
+if redis.call("HGET", KEYS[1], ARGV[1]) == ARGV[2] then
+	return 0
+end
+redis.call("HSET", KEYS[1], ARGV[1], ARGV[2])
+redis.call("HSET", KEYS[2], ARGV[1], ARGV[3])
+return 1
+`)
+
+func newRedisStore(rawURL string) (*redisStore, error) {
+	options, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &redisStore{client: redis.NewClient(options), prefix: "steam-status:"}
+
+	ctx, cancel := s.context()
+	defer cancel()
+
+	if err := s.client.Ping(ctx).Err(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *redisStore) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), 5*time.Second)
+}
+
+func (s *redisStore) key(name string) string {
+	return s.prefix + name
+}
+
+func (s *redisStore) Add(key string, info requestInfo) (bool, error) {
+	data, err := encodeRequest(info)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := s.context()
+	defer cancel()
+	return s.client.HSetNX(ctx, s.key("subscriptions"), key, data).Result()
+}
+
+func (s *redisStore) Put(key string, info requestInfo) error {
+	data, err := encodeRequest(info)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := s.context()
+	defer cancel()
+	return s.client.HSet(ctx, s.key("subscriptions"), key, data).Err()
+}
+
+func (s *redisStore) Get(key string) (requestInfo, bool, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	data, err := s.client.HGet(ctx, s.key("subscriptions"), key).Result()
+	if err == redis.Nil {
+		return requestInfo{}, false, nil
+	}
+	if err != nil {
+		return requestInfo{}, false, err
+	}
+
+	info, err := decodeRequest(data)
+	return info, err == nil, err
+}
+
+func (s *redisStore) Delete(key string) (bool, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	pipe := s.client.TxPipeline()
+	removed := pipe.HDel(ctx, s.key("subscriptions"), key)
+	pipe.HDel(ctx, s.key("statuses"), key)
+	pipe.HDel(ctx, s.key("hashes"), key)
+	pipe.HDel(ctx, s.key("failures"), key)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+
+	return removed.Val() != 0, nil
+}
+
+func (s *redisStore) List() (map[string]requestInfo, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	values, err := s.client.HGetAll(ctx, s.key("subscriptions")).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	requests := make(map[string]requestInfo, len(values))
+	for key, data := range values {
+		info, err := decodeRequest(data)
+		if err != nil {
+			return nil, err
+		}
+		requests[key] = info
+	}
+
+	return requests, nil
+}
+
+func (s *redisStore) Count() (int, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	count, err := s.client.HLen(ctx, s.key("subscriptions")).Result()
+	return int(count), err
+}
+
+func (s *redisStore) GetStatus(key string) (cacheEntry, bool, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	data, err := s.client.HGet(ctx, s.key("statuses"), key).Result()
+	if err == redis.Nil {
+		return cacheEntry{}, false, nil
+	}
+	if err != nil {
+		return cacheEntry{}, false, err
+	}
+
+	entry := cacheEntry{}
+	err = json.Unmarshal([]byte(data), &entry)
+	return entry, err == nil, err
+}
+
+func (s *redisStore) SetStatus(key string, entry cacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := s.context()
+	defer cancel()
+
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, s.key("hashes"), key, entry.Hash)
+	pipe.HSet(ctx, s.key("statuses"), key, string(data))
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *redisStore) SwapStatus(key string, entry cacheEntry) (bool, error) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := s.context()
+	defer cancel()
+
+	swapped, err := swapStatusScript.Run(ctx, s.client, []string{s.key("hashes"), s.key("statuses")}, key, entry.Hash, string(data)).Int()
+	return swapped == 1, err
+}
+
+func (s *redisStore) DeleteStatus(key string) error {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	pipe := s.client.TxPipeline()
+	pipe.HDel(ctx, s.key("hashes"), key)
+	pipe.HDel(ctx, s.key("statuses"), key)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *redisStore) ListStatuses() (map[string]cacheEntry, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	values, err := s.client.HGetAll(ctx, s.key("statuses")).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make(map[string]cacheEntry, len(values))
+	for key, data := range values {
+		entry := cacheEntry{}
+		if err := json.Unmarshal([]byte(data), &entry); err != nil {
+			return nil, err
+		}
+		statuses[key] = entry
+	}
+
+	return statuses, nil
+}
+
+func (s *redisStore) CountStatuses() (int, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	count, err := s.client.HLen(ctx, s.key("statuses")).Result()
+	return int(count), err
+}
+
+func (s *redisStore) AddFailure(key string) (int, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	count, err := s.client.HIncrBy(ctx, s.key("failures"), key, 1).Result()
+	return int(count), err
+}
+
+func (s *redisStore) ResetFailures(key string) error {
+	ctx, cancel := s.context()
+	defer cancel()
+	return s.client.HDel(ctx, s.key("failures"), key).Err()
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+type scraper interface {
+	Scrape(ctx context.Context, page string) (*statusInfo, error)
+}
+
+type collyScraper struct {
+	client *http.Client
+}
+
+var pageScraper scraper
+
+func newCollyScraper(transport http.RoundTripper) collyScraper {
+	return collyScraper{client: newHTTPClient(transport, 0)}
+}
+
+func (s collyScraper) Scrape(ctx context.Context, page string) (*statusInfo, error) {
+	response := scrapeStatus(ctx, s.client, page)
+	if len(response.Error) != 0 {
+		return response, errors.New(response.Error)
+	}
+
+	return response, nil
+}
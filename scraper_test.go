@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func steamServer(t *testing.T, handler http.HandlerFunc) scraper {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	target, _ := url.Parse(server.URL)
+	return newCollyScraper(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.URL.Scheme = target.Scheme
+		req.URL.Host = target.Host
+		return http.DefaultTransport.RoundTrip(req)
+	}))
+}
+
+func TestScrapeTimeoutMovesOn(t *testing.T) {
+	setupTest(t)
+	scrapeTimeout = 100 * time.Millisecond
+
+	source := steamServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/slow/") {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		io.WriteString(w, `<html><body><div class="actual_persona_name">Fast</div></body></html>`)
+	})
+
+	slow := addSubscription("https://steamcommunity.com/id/slow/")
+	fast := addSubscription("https://steamcommunity.com/id/fast/")
+
+	started := time.Now()
+	if _, ok := runCycle(context.Background(), source, make(chan deliveryJob, 10)); !ok {
+		t.Fatal("cycle stopped")
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Fatalf("cycle took %v", elapsed)
+	}
+
+	if state := subscriptionStates[slow]; state.LastScrape != 0 || state.Failures != 1 {
+		t.Fatalf("slow profile recorded %+v", state)
+	}
+	if state := subscriptionStates[fast]; state.LastScrape != 200 {
+		t.Fatalf("fast profile recorded %+v", state)
+	}
+
+	response := gatherStatus(context.Background(), source, "https://steamcommunity.com/id/slow/")
+	if response.Error != "timeout" {
+		t.Fatalf("got error %q, want timeout", response.Error)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+)
+
+func computeSignature(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func signRequest(req *http.Request, secret string, body []byte) {
+	if len(secret) == 0 {
+		return
+	}
+
+	timestamp := strconv.FormatInt(currentClock.Now().Unix(), 10)
+	req.Header.Set("X-Steam-Status-Timestamp", timestamp)
+	req.Header.Set("X-Steam-Status-Signature", "sha256="+computeSignature(secret, timestamp, body))
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	_ "modernc.org/sqlite"
+)
+
+type sqliteStore struct {
+	db *sql.DB
+}
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS subscriptions (key TEXT PRIMARY KEY, info TEXT NOT NULL);
+CREATE TABLE IF NOT EXISTS statuses (key TEXT PRIMARY KEY, entry TEXT NOT NULL);
+CREATE TABLE IF NOT EXISTS delivery_failures (key TEXT PRIMARY KEY, count INTEGER NOT NULL);
+`
+
+func newSQLiteStore(path string) (*sqliteStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &sqliteStore{db: db}, nil
+}
+
+func encodeRequest(info requestInfo) (string, error) {
+	data, err := json.Marshal(persist(info))
+	return string(data), err
+}
+
+func decodeRequest(data string) (requestInfo, error) {
+	stored := persistedRequest{}
+	if err := json.Unmarshal([]byte(data), &stored); err != nil {
+		return requestInfo{}, err
+	}
+
+	return stored.unpack(), nil
+}
+
+func (s *sqliteStore) Add(key string, info requestInfo) (bool, error) {
+	data, err := encodeRequest(info)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := s.db.Exec("INSERT OR IGNORE INTO subscriptions (key, info) VALUES (?, ?)", key, data)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	return rows != 0, err
+}
+
+func (s *sqliteStore) Put(key string, info requestInfo) error {
+	data, err := encodeRequest(info)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec("INSERT OR REPLACE INTO subscriptions (key, info) VALUES (?, ?)", key, data)
+	return err
+}
+
+func (s *sqliteStore) Get(key string) (requestInfo, bool, error) {
+	var data string
+	err := s.db.QueryRow("SELECT info FROM subscriptions WHERE key = ?", key).Scan(&data)
+	if err == sql.ErrNoRows {
+		return requestInfo{}, false, nil
+	}
+	if err != nil {
+		return requestInfo{}, false, err
+	}
+
+	info, err := decodeRequest(data)
+	return info, err == nil, err
+}
+
+func (s *sqliteStore) Delete(key string) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM subscriptions WHERE key = ?", key)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM statuses WHERE key = ?", key); err != nil {
+		return false, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM delivery_failures WHERE key = ?", key); err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows != 0, tx.Commit()
+}
+
+func (s *sqliteStore) List() (map[string]requestInfo, error) {
+	rows, err := s.db.Query("SELECT key, info FROM subscriptions")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := make(map[string]requestInfo)
+	for rows.Next() {
+		var key, data string
+		if err := rows.Scan(&key, &data); err != nil {
+			return nil, err
+		}
+
+		info, err := decodeRequest(data)
+		if err != nil {
+			return nil, err
+		}
+		requests[key] = info
+	}
+
+	return requests, rows.Err()
+}
+
+func (s *sqliteStore) Count() (int, error) {
+	count := 0
+	err := s.db.QueryRow("SELECT COUNT(*) FROM subscriptions").Scan(&count)
+	return count, err
+}
+
+func (s *sqliteStore) GetStatus(key string) (cacheEntry, bool, error) {
+	var data string
+	err := s.db.QueryRow("SELECT entry FROM statuses WHERE key = ?", key).Scan(&data)
+	if err == sql.ErrNoRows {
+		return cacheEntry{}, false, nil
+	}
+	if err != nil {
+		return cacheEntry{}, false, err
+	}
+
+	entry := cacheEntry{}
+	err = json.Unmarshal([]byte(data), &entry)
+	return entry, err == nil, err
+}
+
+func (s *sqliteStore) SetStatus(key string, entry cacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec("INSERT OR REPLACE INTO statuses (key, entry) VALUES (?, ?)", key, string(data))
+	return err
+}
+
+func (s *sqliteStore) SwapStatus(key string, entry cacheEntry) (bool, error) {
+	current, ok, err := s.GetStatus(key)
+	if err != nil {
+		return false, err
+	}
+
+	if ok && current.Hash == entry.Hash {
+		return false, nil
+	}
+
+	return true, s.SetStatus(key, entry)
+}
+
+func (s *sqliteStore) DeleteStatus(key string) error {
+	_, err := s.db.Exec("DELETE FROM statuses WHERE key = ?", key)
+	return err
+}
+
+func (s *sqliteStore) ListStatuses() (map[string]cacheEntry, error) {
+	rows, err := s.db.Query("SELECT key, entry FROM statuses")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	statuses := make(map[string]cacheEntry)
+	for rows.Next() {
+		var key, data string
+		if err := rows.Scan(&key, &data); err != nil {
+			return nil, err
+		}
+
+		entry := cacheEntry{}
+		if err := json.Unmarshal([]byte(data), &entry); err != nil {
+			return nil, err
+		}
+		statuses[key] = entry
+	}
+
+	return statuses, rows.Err()
+}
+
+func (s *sqliteStore) CountStatuses() (int, error) {
+	count := 0
+	err := s.db.QueryRow("SELECT COUNT(*) FROM statuses").Scan(&count)
+	return count, err
+}
+
+func (s *sqliteStore) AddFailure(key string) (int, error) {
+	count := 0
+	err := s.db.QueryRow("INSERT INTO delivery_failures (key, count) VALUES (?, 1) ON CONFLICT (key) DO UPDATE SET count = count + 1 RETURNING count", key).Scan(&count)
+	return count, err
+}
+
+func (s *sqliteStore) ResetFailures(key string) error {
+	_, err := s.db.Exec("DELETE FROM delivery_failures WHERE key = ?", key)
+	return err
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+var allowPrivate bool
+
+var errPrivateAddress = errors.New("address is private or loopback")
+
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified()
+}
+
+func checkPublicHost(rawURL string) error {
+	if allowPrivate {
+		return nil
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, parsed.Hostname())
+	if err != nil {
+		return err
+	}
+
+	for _, addr := range addrs {
+		if isPrivateIP(addr.IP) {
+			return errPrivateAddress
+		}
+	}
+
+	return nil
+}
+
+func dialControl(network string, address string, c syscall.RawConn) error {
+	if allowPrivate {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || isPrivateIP(ip) {
+		return fmt.Errorf("refusing to connect to %s: %w", host, errPrivateAddress)
+	}
+
+	return nil
+}
+
+func newSafeTransport(dialTimeout time.Duration) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   dialTimeout,
+		KeepAlive: 30 * time.Second,
+		Control:   dialControl,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	return transport
+}
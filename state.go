@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type persistedRequest struct {
+	requestInfo
+	Original  string
+	Owner     string
+	ExpiresAt time.Time
+	Sequence  int64
+}
+
+type persistedState struct {
+	Requests []persistedRequest
+	Cache    map[string]cacheEntry
+}
+
+var stateFile string
+var stateDirty = make(chan struct{}, 1)
+
+func persist(info requestInfo) persistedRequest {
+	return persistedRequest{info, info.Original, info.Owner, info.ExpiresAt, info.Sequence}
+}
+
+func (p *persistedRequest) unpack() requestInfo {
+	info := p.requestInfo
+	info.Original = p.Original
+	info.Owner = p.Owner
+	info.ExpiresAt = p.ExpiresAt
+	info.Sequence = p.Sequence
+	return info
+}
+
+func markStateDirty() {
+	subscriptionsRevision.Add(1)
+
+	if len(stateFile) == 0 {
+		return
+	}
+
+	select {
+	case stateDirty <- struct{}{}:
+	default:
+	}
+}
+
+func saveState() error {
+	requests, err := subscriptions.List()
+	if err != nil {
+		return err
+	}
+
+	statuses, err := subscriptions.ListStatuses()
+	if err != nil {
+		return err
+	}
+
+	state := persistedState{Cache: statuses}
+	for _, info := range requests {
+		state.Requests = append(state.Requests, persist(info))
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	temp, err := ioutil.TempFile(filepath.Dir(stateFile), filepath.Base(stateFile)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+
+	if err := temp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), stateFile)
+}
+
+func loadState() {
+	data, err := ioutil.ReadFile(stateFile)
+	if err != nil {
+		slog.Info("Starting with no saved state", "err", err)
+		return
+	}
+
+	state := persistedState{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		slog.Warn("Ignoring corrupt state file", "err", err)
+		return
+	}
+
+	for _, stored := range state.Requests {
+		info := stored.unpack()
+
+		key := subscriptionID(&info)
+		subscriptions.Put(key, info)
+		if entry, ok := state.Cache[key]; ok {
+			subscriptions.SetStatus(key, entry)
+		}
+	}
+
+	slog.Info("Restored subscriptions", "count", len(state.Requests), "file", stateFile)
+}
+
+func runStateWriter(ctx context.Context, debounce time.Duration) {
+	for {
+		select {
+		case <-stateDirty:
+			sleepContext(ctx, debounce)
+			if err := saveState(); err != nil {
+				slog.Error("Failed to save state", "err", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
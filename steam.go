@@ -0,0 +1,192 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var steamIDPattern = regexp.MustCompile(`^[0-9]{17}$`)
+var vanityPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{2,32}$`)
+
+func canonicalPage(raw string) (string, string) {
+	if err := validateURL(raw, "page"); err != nil {
+		return "", err.Message
+	}
+
+	parsed, _ := url.Parse(raw)
+
+	host := strings.ToLower(parsed.Hostname())
+	if host != "steamcommunity.com" && host != "www.steamcommunity.com" {
+		return "", "page must be a steamcommunity.com profile"
+	}
+
+	segments := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if len(segments) != 2 {
+		return "", "page must be a /id/<vanity> or /profiles/<steamid64> url"
+	}
+
+	switch segments[0] {
+	case "id":
+		if !vanityPattern.MatchString(segments[1]) {
+			return "", "page has an invalid vanity name"
+		}
+
+		return "https://steamcommunity.com/id/" + strings.ToLower(segments[1]), ""
+	case "profiles":
+		if !steamIDPattern.MatchString(segments[1]) {
+			return "", "page has an invalid steamid64"
+		}
+
+		return profileURL(segments[1]), ""
+	}
+
+	return "", "page must be a /id/<vanity> or /profiles/<steamid64> url"
+}
+
+func isSteamID(id string) bool {
+	return steamIDPattern.MatchString(id) && strings.HasPrefix(id, "7656")
+}
+
+func profileURL(id string) string {
+	return "https://steamcommunity.com/profiles/" + id
+}
+
+func steamIDFromPage(page string) string {
+	if strings.HasPrefix(page, "https://steamcommunity.com/profiles/") {
+		return strings.TrimPrefix(page, "https://steamcommunity.com/profiles/")
+	}
+
+	return ""
+}
+
+var hoursPatterns = map[string]*regexp.Regexp{
+	"on record":    regexp.MustCompile(`([0-9][0-9,]*(?:\.[0-9]+)?)\s*hrs?\s+on record`),
+	"past 2 weeks": regexp.MustCompile(`([0-9][0-9,]*(?:\.[0-9]+)?)\s*hrs?\s+past 2 weeks`),
+}
+
+func parseHours(details string, label string) float64 {
+	match := hoursPatterns[label].FindStringSubmatch(details)
+	if match == nil {
+		return -1
+	}
+
+	hours, err := strconv.ParseFloat(strings.ReplaceAll(match[1], ",", ""), 64)
+	if err != nil {
+		return -1
+	}
+
+	return hours
+}
+
+var lastOnlinePattern = regexp.MustCompile(`(\d+)\s*(sec|min|hr|hour|day|week|month|year)s?`)
+
+var lastOnlineUnits = map[string]time.Duration{
+	"sec":   time.Second,
+	"min":   time.Minute,
+	"hr":    time.Hour,
+	"hour":  time.Hour,
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+	"year":  365 * 24 * time.Hour,
+}
+
+func parseLastOnline(text string, now time.Time) (time.Time, bool) {
+	text = strings.ToLower(strings.Join(strings.Fields(text), " "))
+
+	if strings.Contains(text, "currently online") || strings.Contains(text, "currently in-game") {
+		return now, true
+	}
+
+	if !strings.Contains(text, "last online") || !strings.HasSuffix(text, "ago") {
+		return time.Time{}, false
+	}
+
+	matches := lastOnlinePattern.FindAllStringSubmatch(text, -1)
+	if len(matches) == 0 {
+		return time.Time{}, false
+	}
+
+	elapsed := time.Duration(0)
+	for _, match := range matches {
+		count, _ := strconv.Atoi(match[1])
+		elapsed += time.Duration(count) * lastOnlineUnits[match[2]]
+	}
+
+	return now.Add(-elapsed), true
+}
+
+var appIDPattern = regexp.MustCompile(`/app/([0-9]+)`)
+
+func parseAppID(link string) int {
+	match := appIDPattern.FindStringSubmatch(link)
+	if match == nil {
+		return 0
+	}
+
+	id, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0
+	}
+
+	return id
+}
+
+func parseLevel(raw string) (int, error) {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, raw)
+
+	return strconv.Atoi(digits)
+}
+
+func normalizeAvatar(raw string) string {
+	if len(raw) == 0 {
+		return ""
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+
+	parsed.Scheme = "https"
+	parsed.RawQuery = ""
+	parsed.Fragment = ""
+
+	switch {
+	case strings.HasSuffix(parsed.Path, "_full.jpg"):
+	case strings.HasSuffix(parsed.Path, "_medium.jpg"):
+		parsed.Path = strings.TrimSuffix(parsed.Path, "_medium.jpg") + "_full.jpg"
+	case strings.HasSuffix(parsed.Path, ".jpg"):
+		parsed.Path = strings.TrimSuffix(parsed.Path, ".jpg") + "_full.jpg"
+	}
+
+	return parsed.String()
+}
+
+func englishURL(page string) string {
+	parsed, err := url.Parse(page)
+	if err != nil {
+		return page
+	}
+
+	query := parsed.Query()
+	query.Set("l", "english")
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+func normalizePage(raw string) string {
+	if page, reason := canonicalPage(raw); len(reason) == 0 {
+		return page
+	}
+
+	return raw
+}
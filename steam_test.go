@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func fixtureScraper(t *testing.T, name string) scraper {
+	page, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return steamServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		w.Write(page)
+	})
+}
+
+func TestScrapeStatusFixtures(t *testing.T) {
+	tests := []struct {
+		fixture   string
+		playing   bool
+		game      string
+		link      string
+		appID     int
+		online    string
+		onRecord  float64
+		pastWeeks float64
+	}{
+		{"profile_in_game.html", true, "Portal 2", "https://store.steampowered.com/app/620/", 620, "in-game", -1, -1},
+		{"profile_in_game_unclassed.html", true, "Portal 2", "https://store.steampowered.com/app/620/", 620, "in-game", -1, -1},
+		{"profile_offline.html", false, "Half-Life: Alyx", "https://steamcommunity.com/app/546560", 546560, "offline", 1204.5, 4.2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.fixture, func(t *testing.T) {
+			setupTest(t)
+
+			response, err := fixtureScraper(t, test.fixture).Scrape(context.Background(), "https://steamcommunity.com/id/fixture/")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if response.StatusCode != 200 || response.IsPlaying != test.playing || response.GameName != test.game || response.GameLink != test.link || response.AppID != test.appID {
+				t.Fatalf("got %+v", response)
+			}
+
+			if response.OnlineState != test.online || response.HoursOnRecord != test.onRecord || response.HoursPastTwoWeeks != test.pastWeeks {
+				t.Fatalf("got state %q with %v hours on record and %v past two weeks", response.OnlineState, response.HoursOnRecord, response.HoursPastTwoWeeks)
+			}
+
+			if len(response.RecentGames) == 0 || response.Visibility != "public" || len(response.PersonaName) == 0 {
+				t.Fatalf("got %+v", response)
+			}
+		})
+	}
+}
+
+func TestGatherStatusRetries(t *testing.T) {
+	setupTest(t)
+	scrapeAttempts = 3
+
+	requests := atomic.Int32{}
+	source := steamServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, `<html><body><div class="actual_persona_name">Retried</div></body></html>`)
+	})
+
+	response := gatherStatus(context.Background(), source, "https://steamcommunity.com/id/retry/")
+	if response.StatusCode != 200 || response.Attempts != 3 || response.PersonaName != "Retried" {
+		t.Fatalf("got status %d after %d attempts", response.StatusCode, response.Attempts)
+	}
+}
+
+func TestOnDemandStatusDeadline(t *testing.T) {
+	setupTest(t)
+	scrapeAttempts = 3
+	scrapeRetryDelay = 200 * time.Millisecond
+	statusTimeout = 300 * time.Millisecond
+
+	pageScraper = steamServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	started := time.Now()
+	response, ok := onDemandStatus(context.Background(), "https://steamcommunity.com/id/deadline/")
+	if elapsed := time.Since(started); elapsed > 500*time.Millisecond {
+		t.Fatalf("on-demand lookup took %v", elapsed)
+	}
+	if !ok || response.StatusCode != http.StatusServiceUnavailable || response.Attempts != 2 {
+		t.Fatalf("got %+v", response)
+	}
+}
+
+func TestStatusHandlerRespectsThrottle(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func()
+	}{
+		{"breaker open", func() { scrapeBreaker.transition(breakerOpen) }},
+		{"throttled", func() { recordThrottle(time.Hour) }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setupTest(t)
+			statusTimeout = 100 * time.Millisecond
+			cooldown := scrapeBreaker.cooldown
+			t.Cleanup(func() {
+				scrapeBreaker.transition(breakerClosed)
+				scrapeBreaker.cooldown = cooldown
+				throttleLock.Lock()
+				throttleUntil = time.Time{}
+				throttleStrikes = 0
+				throttleLock.Unlock()
+			})
+
+			source := &fakeScraper{responses: []*statusInfo{{StatusCode: 200}}}
+			pageScraper = source
+			scrapeBreaker.cooldown = time.Hour
+			test.setup()
+
+			recorder := httptest.NewRecorder()
+			statusHandler(recorder, httptest.NewRequest(http.MethodGet, "/status?page=https://steamcommunity.com/id/throttled/", nil))
+
+			if recorder.Code != http.StatusServiceUnavailable || len(source.scraped()) != 0 {
+				t.Fatalf("got %d after %d scrapes", recorder.Code, len(source.scraped()))
+			}
+		})
+	}
+}
+
+func TestParseLastOnline(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		text    string
+		elapsed time.Duration
+		ok      bool
+	}{
+		{"Last Online 3 hrs, 12 mins ago", 3*time.Hour + 12*time.Minute, true},
+		{"Last Online 2 days ago", 48 * time.Hour, true},
+		{"Last Online 1 hr, 1 min ago", time.Hour + time.Minute, true},
+		{"Last Online 45 mins ago", 45 * time.Minute, true},
+		{"  Last   Online\n\t5 days ago ", 5 * 24 * time.Hour, true},
+		{"Last Online 1 week ago", 7 * 24 * time.Hour, true},
+		{"Currently Online", 0, true},
+		{"Currently In-Game", 0, true},
+		{"Last Online 20 Jan", 0, false},
+		{"Currently Offline", 0, false},
+		{"", 0, false},
+	}
+
+	for _, test := range tests {
+		parsed, ok := parseLastOnline(test.text, now)
+		if ok != test.ok {
+			t.Errorf("%q: ok = %v, want %v", test.text, ok, test.ok)
+			continue
+		}
+
+		if ok && !parsed.Equal(now.Add(-test.elapsed)) {
+			t.Errorf("%q: got %v, want %v", test.text, parsed, now.Add(-test.elapsed))
+		}
+	}
+}
+
+func TestScrapeStatusGermanProfile(t *testing.T) {
+	setupTest(t)
+
+	page, err := os.ReadFile(filepath.Join("testdata", "profile_in_game_german.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	language := ""
+	tab := ""
+	cookie := ""
+	source := steamServer(t, func(w http.ResponseWriter, r *http.Request) {
+		language = r.URL.Query().Get("l")
+		tab = r.URL.Query().Get("tab")
+		if value, err := r.Cookie("Steam_Language"); err == nil {
+			cookie = value.Value
+		}
+		w.Write(page)
+	})
+
+	response, err := source.Scrape(context.Background(), "https://steamcommunity.com/id/german/?tab=all")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if language != "english" || tab != "all" || cookie != "english" {
+		t.Fatalf("requested language %q and tab %q with cookie %q", language, tab, cookie)
+	}
+
+	if !response.IsPlaying || response.OnlineState != "in-game" || response.GameName != "Portal 2" || response.AppID != 620 {
+		t.Fatalf("got %+v", response)
+	}
+}
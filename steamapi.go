@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var steamAPIKey string
+
+const steamAPIBase = "https://api.steampowered.com"
+
+func redactAPIError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return errors.New(urlErr.Op + " " + steamAPIBase + ": " + urlErr.Err.Error())
+	}
+
+	return err
+}
+
+func resolveVanity(vanity string) (string, error) {
+	query := url.Values{}
+	query.Set("key", steamAPIKey)
+	query.Set("vanityurl", vanity)
+
+	response, err := steamClient.Get(steamAPIBase + "/ISteamUser/ResolveVanityURL/v1/?" + query.Encode())
+	if err != nil {
+		return "", redactAPIError(err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != 200 {
+		return "", errors.New("steam api returned " + response.Status)
+	}
+
+	var body struct {
+		Response struct {
+			SteamID string `json:"steamid"`
+			Success int    `json:"success"`
+			Message string `json:"message"`
+		} `json:"response"`
+	}
+
+	if err := json.NewDecoder(io.LimitReader(response.Body, int64(scrapeMaxBytes))).Decode(&body); err != nil {
+		return "", err
+	}
+
+	if body.Response.Success != 1 || !isSteamID(body.Response.SteamID) {
+		return "", errors.New("could not resolve vanity url: " + body.Response.Message)
+	}
+
+	return body.Response.SteamID, nil
+}
+
+func resolvePage(page string) string {
+	if len(steamAPIKey) == 0 || !strings.HasPrefix(page, "https://steamcommunity.com/id/") {
+		return page
+	}
+
+	id, err := resolveVanity(strings.TrimPrefix(page, "https://steamcommunity.com/id/"))
+	if err != nil {
+		slog.Warn("Failed to resolve page", "page", page, "err", err)
+		return page
+	}
+
+	return profileURL(id)
+}
+
+type playerSummary struct {
+	SteamID                  string `json:"steamid"`
+	GameID                   string `json:"gameid"`
+	GameExtraInfo            string `json:"gameextrainfo"`
+	CommunityVisibilityState int    `json:"communityvisibilitystate"`
+	PersonaName              string `json:"personaname"`
+	AvatarFull               string `json:"avatarfull"`
+	PersonaState             int    `json:"personastate"`
+	LastLogoff               int64  `json:"lastlogoff"`
+}
+
+func summaryStatus(summary *playerSummary) *statusInfo {
+	response := &statusInfo{StatusCode: 200, Source: "api", Visibility: "private", Level: -1, HoursOnRecord: -1, HoursPastTwoWeeks: -1, PersonaName: summary.PersonaName, AvatarURL: normalizeAvatar(summary.AvatarFull)}
+
+	switch summary.PersonaState {
+	case 0:
+		response.OnlineState = "offline"
+		if summary.LastLogoff > 0 {
+			response.LastOnline = time.Unix(summary.LastLogoff, 0).UTC().Format(time.RFC3339)
+		}
+	case 3, 4:
+		response.OnlineState = "away"
+	default:
+		response.OnlineState = "online"
+	}
+
+	switch summary.CommunityVisibilityState {
+	case 2:
+		response.Visibility = "friendsOnly"
+	case 3:
+		response.Visibility = "public"
+	}
+
+	if len(summary.GameID) != 0 {
+		response.IsPlaying = true
+		response.OnlineState = "in-game"
+		response.GameName = summary.GameExtraInfo
+		response.GameLink = "https://steamcommunity.com/app/" + summary.GameID
+		response.GameIcon = "https://cdn.cloudflare.steamstatic.com/steam/apps/" + summary.GameID + "/capsule_184x69.jpg"
+		response.AppID = parseAppID(response.GameLink)
+	}
+
+	return response
+}
+
+func fetchSummaries(ctx context.Context, ids []string) map[string]*statusInfo {
+	statuses := make(map[string]*statusInfo)
+
+	if len(steamAPIKey) == 0 {
+		return statuses
+	}
+
+	for start := 0; start < len(ids); start += 100 {
+		end := start + 100
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		query := url.Values{}
+		query.Set("key", steamAPIKey)
+		query.Set("steamids", strings.Join(ids[start:end], ","))
+
+		req, err := http.NewRequestWithContext(ctx, "GET", steamAPIBase+"/ISteamUser/GetPlayerSummaries/v2/?"+query.Encode(), nil)
+		if err != nil {
+			continue
+		}
+
+		started := time.Now()
+		response, err := steamClient.Do(req)
+		if err != nil {
+			recordScrape(0, started)
+			slog.Warn("Failed to fetch player summaries", "err", redactAPIError(err))
+			continue
+		}
+
+		var body struct {
+			Response struct {
+				Players []playerSummary `json:"players"`
+			} `json:"response"`
+		}
+
+		err = json.NewDecoder(io.LimitReader(response.Body, int64(scrapeMaxBytes))).Decode(&body)
+		response.Body.Close()
+		recordScrape(response.StatusCode, started)
+
+		if response.StatusCode != 200 || err != nil {
+			slog.Warn("Failed to fetch player summaries", "status", response.StatusCode)
+			continue
+		}
+
+		for i := range body.Response.Players {
+			statuses[body.Response.Players[i].SteamID] = summaryStatus(&body.Response.Players[i])
+		}
+	}
+
+	return statuses
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+)
+
+type store interface {
+	Add(key string, info requestInfo) (bool, error)
+	Put(key string, info requestInfo) error
+	Get(key string) (requestInfo, bool, error)
+	Delete(key string) (bool, error)
+	List() (map[string]requestInfo, error)
+	Count() (int, error)
+
+	GetStatus(key string) (cacheEntry, bool, error)
+	SetStatus(key string, entry cacheEntry) error
+	SwapStatus(key string, entry cacheEntry) (bool, error)
+	DeleteStatus(key string) error
+	ListStatuses() (map[string]cacheEntry, error)
+	CountStatuses() (int, error)
+
+	AddFailure(key string) (int, error)
+	ResetFailures(key string) error
+}
+
+type memoryStore struct {
+	lock     sync.Mutex
+	requests map[string]requestInfo
+	statuses map[string]cacheEntry
+	failures map[string]int
+}
+
+var subscriptions store = newMemoryStore()
+
+func openStore(redisURL string, dbPath string) (store, error) {
+	switch {
+	case len(redisURL) != 0 && len(dbPath) != 0:
+		return nil, errors.New("only one of -redis and -db can be set")
+	case len(redisURL) != 0:
+		return newRedisStore(redisURL)
+	case len(dbPath) != 0:
+		return newSQLiteStore(dbPath)
+	}
+
+	return newMemoryStore(), nil
+}
+
+func rekeyStore(s store) error {
+	requests, err := s.List()
+	if err != nil {
+		return err
+	}
+
+	for key, info := range requests {
+		id := subscriptionID(&info)
+		if key == id {
+			continue
+		}
+
+		entry, hasStatus, err := s.GetStatus(key)
+		if err != nil {
+			return err
+		}
+
+		if _, err := s.Delete(key); err != nil {
+			return err
+		}
+
+		if _, err := s.Add(id, info); err != nil {
+			return err
+		}
+
+		if hasStatus {
+			if err := s.SetStatus(id, entry); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func storeSizes() (int, int) {
+	queueSize, err := subscriptions.Count()
+	if err != nil {
+		slog.Error("Failed to count subscriptions", "err", err)
+	}
+
+	cacheSize, err := subscriptions.CountStatuses()
+	if err != nil {
+		slog.Error("Failed to count statuses", "err", err)
+	}
+
+	return queueSize, cacheSize
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		requests: make(map[string]requestInfo),
+		statuses: make(map[string]cacheEntry),
+		failures: make(map[string]int),
+	}
+}
+
+func (m *memoryStore) Add(key string, info requestInfo) (bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, ok := m.requests[key]; ok {
+		return false, nil
+	}
+	m.requests[key] = info
+
+	return true, nil
+}
+
+func (m *memoryStore) Put(key string, info requestInfo) error {
+	m.lock.Lock()
+	m.requests[key] = info
+	m.lock.Unlock()
+	return nil
+}
+
+func (m *memoryStore) Get(key string) (requestInfo, bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	info, ok := m.requests[key]
+	return info, ok, nil
+}
+
+func (m *memoryStore) Delete(key string) (bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	_, ok := m.requests[key]
+	delete(m.requests, key)
+	delete(m.statuses, key)
+	delete(m.failures, key)
+
+	return ok, nil
+}
+
+func (m *memoryStore) List() (map[string]requestInfo, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	requests := make(map[string]requestInfo, len(m.requests))
+	for key, info := range m.requests {
+		requests[key] = info
+	}
+
+	return requests, nil
+}
+
+func (m *memoryStore) Count() (int, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return len(m.requests), nil
+}
+
+func (m *memoryStore) GetStatus(key string) (cacheEntry, bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	entry, ok := m.statuses[key]
+	return entry, ok, nil
+}
+
+func (m *memoryStore) SetStatus(key string, entry cacheEntry) error {
+	m.lock.Lock()
+	m.statuses[key] = entry
+	m.lock.Unlock()
+	return nil
+}
+
+func (m *memoryStore) SwapStatus(key string, entry cacheEntry) (bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if current, ok := m.statuses[key]; ok && current.Hash == entry.Hash {
+		return false, nil
+	}
+	m.statuses[key] = entry
+
+	return true, nil
+}
+
+func (m *memoryStore) DeleteStatus(key string) error {
+	m.lock.Lock()
+	delete(m.statuses, key)
+	m.lock.Unlock()
+	return nil
+}
+
+func (m *memoryStore) ListStatuses() (map[string]cacheEntry, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	statuses := make(map[string]cacheEntry, len(m.statuses))
+	for key, entry := range m.statuses {
+		statuses[key] = entry
+	}
+
+	return statuses, nil
+}
+
+func (m *memoryStore) CountStatuses() (int, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return len(m.statuses), nil
+}
+
+func (m *memoryStore) AddFailure(key string) (int, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.failures[key]++
+	return m.failures[key], nil
+}
+
+func (m *memoryStore) ResetFailures(key string) error {
+	m.lock.Lock()
+	delete(m.failures, key)
+	m.lock.Unlock()
+	return nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func testStore(t *testing.T, s store) {
+	info := requestInfo{Page: "https://steamcommunity.com/id/store/", Token: "token", Callback: "https://example.com/callback", Format: "json"}
+
+	added, err := s.Add("first", info)
+	if err != nil || !added {
+		t.Fatalf("add returned %v, %v", added, err)
+	}
+
+	if added, err := s.Add("first", requestInfo{Token: "other"}); err != nil || added {
+		t.Fatalf("second add returned %v, %v", added, err)
+	}
+
+	info.Token = "rotated"
+	if err := s.Put("first", info); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, ok, err := s.Get("first")
+	if err != nil || !ok || stored.Page != info.Page || stored.Token != "rotated" || stored.Callback != info.Callback {
+		t.Fatalf("get returned %+v, %v, %v", stored, ok, err)
+	}
+
+	if _, ok, err := s.Get("missing"); err != nil || ok {
+		t.Fatalf("get of a missing key returned %v, %v", ok, err)
+	}
+
+	if err := s.Put("second", info); err != nil {
+		t.Fatal(err)
+	}
+
+	requests, err := s.List()
+	if err != nil || len(requests) != 2 || requests["second"].Token != "rotated" {
+		t.Fatalf("list returned %+v, %v", requests, err)
+	}
+
+	if count, err := s.Count(); err != nil || count != 2 {
+		t.Fatalf("count returned %d, %v", count, err)
+	}
+
+	entry := cacheEntry{Hash: "v2|first", Status: statusInfo{StatusCode: 200, IsPlaying: true, GameName: "Portal"}, Updated: time.Unix(1700000000, 0).UTC()}
+	if err := s.SetStatus("first", entry); err != nil {
+		t.Fatal(err)
+	}
+
+	current, ok, err := s.GetStatus("first")
+	if err != nil || !ok || current.Hash != entry.Hash || current.Status.GameName != "Portal" || !current.Updated.Equal(entry.Updated) {
+		t.Fatalf("get status returned %+v, %v, %v", current, ok, err)
+	}
+
+	if swapped, err := s.SwapStatus("first", entry); err != nil || swapped {
+		t.Fatalf("swap with the same hash returned %v, %v", swapped, err)
+	}
+
+	entry.Hash = "v2|changed"
+	if swapped, err := s.SwapStatus("first", entry); err != nil || !swapped {
+		t.Fatalf("swap with a new hash returned %v, %v", swapped, err)
+	}
+
+	if swapped, err := s.SwapStatus("second", entry); err != nil || !swapped {
+		t.Fatalf("swap without a status returned %v, %v", swapped, err)
+	}
+
+	statuses, err := s.ListStatuses()
+	if err != nil || len(statuses) != 2 || statuses["first"].Hash != "v2|changed" {
+		t.Fatalf("list statuses returned %+v, %v", statuses, err)
+	}
+
+	if err := s.DeleteStatus("second"); err != nil {
+		t.Fatal(err)
+	}
+
+	if count, err := s.CountStatuses(); err != nil || count != 1 {
+		t.Fatalf("count statuses returned %d, %v", count, err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		if count, err := s.AddFailure("first"); err != nil || count != i {
+			t.Fatalf("failure %d counted as %d, %v", i, count, err)
+		}
+	}
+
+	if err := s.ResetFailures("first"); err != nil {
+		t.Fatal(err)
+	}
+
+	if count, err := s.AddFailure("first"); err != nil || count != 1 {
+		t.Fatalf("failure after reset counted as %d, %v", count, err)
+	}
+
+	if removed, err := s.Delete("first"); err != nil || !removed {
+		t.Fatalf("delete returned %v, %v", removed, err)
+	}
+
+	if removed, err := s.Delete("first"); err != nil || removed {
+		t.Fatalf("second delete returned %v, %v", removed, err)
+	}
+
+	if _, ok, err := s.GetStatus("first"); err != nil || ok {
+		t.Fatalf("status survived delete: %v, %v", ok, err)
+	}
+
+	if count, err := s.AddFailure("first"); err != nil || count != 1 {
+		t.Fatalf("failures survived delete: %d, %v", count, err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, newMemoryStore())
+}
+
+func TestSQLiteStore(t *testing.T) {
+	s, err := newSQLiteStore(filepath.Join(t.TempDir(), "store.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.db.Close() })
+
+	testStore(t, s)
+}
+
+func TestRedisStore(t *testing.T) {
+	url := os.Getenv("REDIS_URL")
+	if len(url) == 0 {
+		t.Skip("REDIS_URL is not set")
+	}
+
+	s, err := newRedisStore(url)
+	if err != nil {
+		t.Skip(err)
+	}
+	s.prefix = "steam-status-test:" + strconv.FormatInt(time.Now().UnixNano(), 10) + ":"
+	t.Cleanup(func() {
+		ctx, cancel := s.context()
+		defer cancel()
+		s.client.Del(ctx, s.key("subscriptions"), s.key("statuses"), s.key("hashes"), s.key("failures"))
+		s.client.Close()
+	})
+
+	testStore(t, s)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const maxThrottleStrikes = 5
+
+var throttleUntil time.Time
+var throttleStrikes int
+var throttleLock sync.Mutex
+
+func parseRetryAfter(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := date.Sub(currentClock.Now()); delay > 0 {
+			return delay
+		}
+	}
+
+	return time.Minute
+}
+
+func recordThrottle(delay time.Duration) {
+	delay += time.Duration(rand.Int63n(int64(delay)/4 + 1))
+
+	throttleLock.Lock()
+	if until := currentClock.Now().Add(delay); until.After(throttleUntil) {
+		throttleUntil = until
+	}
+	if throttleStrikes < maxThrottleStrikes {
+		throttleStrikes++
+	}
+	throttleLock.Unlock()
+}
+
+func recordThrottleRecovery() {
+	throttleLock.Lock()
+	throttleStrikes = 0
+	throttleLock.Unlock()
+}
+
+func throttled() bool {
+	throttleLock.Lock()
+	defer throttleLock.Unlock()
+	return throttleStrikes != 0 || currentClock.Now().Before(throttleUntil)
+}
+
+func waitThrottle(ctx context.Context) bool {
+	throttleLock.Lock()
+	delay := throttleUntil.Sub(currentClock.Now())
+	throttleLock.Unlock()
+
+	if delay <= 0 {
+		return ctx.Err() == nil
+	}
+
+	return sleepContext(ctx, delay)
+}
+
+func throttledInterval(interval time.Duration) time.Duration {
+	throttleLock.Lock()
+	defer throttleLock.Unlock()
+	return interval << throttleStrikes
+}
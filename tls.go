@@ -0,0 +1,67 @@
+package main
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+type certReloader struct {
+	certPath string
+	keyPath  string
+	reload   bool
+	lock     sync.Mutex
+	cert     *tls.Certificate
+	modTime  time.Time
+}
+
+func newCertReloader(certPath string, keyPath string, reload bool) (*certReloader, error) {
+	reloader := &certReloader{certPath: certPath, keyPath: keyPath, reload: reload}
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+
+	return reloader, nil
+}
+
+func (c *certReloader) latestModTime() time.Time {
+	latest := time.Time{}
+
+	for _, path := range []string{c.certPath, c.keyPath} {
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest
+}
+
+func (c *certReloader) load() error {
+	modTime := c.latestModTime()
+
+	cert, err := tls.LoadX509KeyPair(c.certPath, c.keyPath)
+	if err != nil {
+		return err
+	}
+
+	c.cert = &cert
+	c.modTime = modTime
+	return nil
+}
+
+func (c *certReloader) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.reload && c.latestModTime().After(c.modTime) {
+		if err := c.load(); err != nil {
+			slog.Error("Failed to reload certificate", "err", err)
+		} else {
+			slog.Info("Reloaded certificate")
+		}
+	}
+
+	return c.cert, nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/contrib/exporters/autoexport"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/TerrayTM/steam-status")
+
+func setupTracing(ctx context.Context) (func(context.Context) error, error) {
+	if len(os.Getenv("OTEL_TRACES_EXPORTER")) == 0 {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := autoexport.NewSpanExporter(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
+
+func injectTrace(ctx context.Context, req *http.Request) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+}
+
+func finishScrapeSpan(span trace.Span, response *statusInfo) {
+	span.SetAttributes(attribute.Int("http.status_code", response.StatusCode), attribute.Int("steam.attempts", response.Attempts))
+	if response.StatusCode != http.StatusOK {
+		span.SetStatus(codes.Error, response.Error)
+	}
+}
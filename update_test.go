@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRunCycle(t *testing.T) {
+	playing := &statusInfo{StatusCode: 200, IsPlaying: true, GameName: "Portal", AppID: 400, Visibility: "public"}
+	switched := &statusInfo{StatusCode: 200, IsPlaying: true, GameName: "Portal 2", AppID: 620, Visibility: "public"}
+	missing := &statusInfo{StatusCode: 404, ProfileMissing: true}
+
+	tests := []struct {
+		name       string
+		responses  []*statusInfo
+		cycles     int
+		callback   int
+		deliveries int
+		kept       bool
+	}{
+		{"unchanged", []*statusInfo{playing, playing}, 2, http.StatusOK, 1, true},
+		{"changed", []*statusInfo{playing, switched}, 2, http.StatusOK, 2, true},
+		{"missing", []*statusInfo{missing}, 3, http.StatusOK, 1, false},
+		{"callback gone", []*statusInfo{playing}, 1, http.StatusGone, 1, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setupTest(t)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.callback)
+				io.WriteString(w, `{"success":true,"data":{"refresh":"token"}}`)
+			}))
+			defer server.Close()
+
+			info := requestInfo{Page: "https://steamcommunity.com/id/cycle/", Token: "token", Callback: server.URL, Format: "json"}
+			key := subscriptionID(&info)
+			subscriptions.Put(key, info)
+
+			source := &fakeScraper{responses: test.responses}
+			deliveries := make(chan deliveryJob, 10)
+			delivered := 0
+
+			for i := 0; i < test.cycles; i++ {
+				if _, ok := runCycle(context.Background(), source, deliveries); !ok {
+					t.Fatal("cycle stopped")
+				}
+
+				for len(deliveries) != 0 {
+					delivered++
+					deliverStatus(context.Background(), server.Client(), <-deliveries)
+				}
+			}
+
+			if len(source.scraped()) != test.cycles {
+				t.Fatalf("scraped %d times, want %d", len(source.scraped()), test.cycles)
+			}
+
+			if delivered != test.deliveries {
+				t.Fatalf("delivered %d times, want %d", delivered, test.deliveries)
+			}
+
+			if _, ok, _ := subscriptions.Get(key); ok != test.kept {
+				t.Fatalf("subscription kept = %v, want %v", ok, test.kept)
+			}
+		})
+	}
+}
+
+func TestStatusTransitions(t *testing.T) {
+	idle := statusInfo{StatusCode: 200, Visibility: "public", OnlineState: "online"}
+	portal := statusInfo{StatusCode: 200, IsPlaying: true, GameName: "Portal", GameLink: "https://steamcommunity.com/app/400", AppID: 400, Visibility: "public", OnlineState: "in-game"}
+	firstMod := statusInfo{StatusCode: 200, IsPlaying: true, GameName: "Black Mesa Source", Visibility: "public", OnlineState: "in-game"}
+	secondMod := statusInfo{StatusCode: 200, IsPlaying: true, GameName: "Minerva", Visibility: "public", OnlineState: "in-game"}
+
+	tests := []struct {
+		name       string
+		previous   statusInfo
+		legacy     bool
+		current    statusInfo
+		transition string
+		delivered  bool
+	}{
+		{"start playing", idle, false, portal, "start", true},
+		{"stop playing", portal, false, idle, "stop", true},
+		{"switch game", portal, false, firstMod, "change", true},
+		{"switch game without links", firstMod, false, secondMod, "change", true},
+		{"same game rescraped", portal, false, portal, "change", false},
+		{"upgraded hash", portal, true, portal, "change", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setupTest(t)
+
+			info := requestInfo{Page: "https://steamcommunity.com/id/transition/", Token: "token", Callback: "https://example.com/callback", Format: "json"}
+			key := subscriptionID(&info)
+			subscriptions.Put(key, info)
+
+			hash := hashStatus(&test.previous, &info)
+			if test.legacy {
+				hash = test.previous.GameLink + "|" + strconv.FormatBool(test.previous.IsPlaying) + "|" + info.Callback
+			}
+			subscriptions.SetStatus(key, cacheEntry{Hash: hash, Status: test.previous})
+
+			if transition := statusTransition(&test.previous, &test.current); transition != test.transition {
+				t.Fatalf("transition %q, want %q", transition, test.transition)
+			}
+
+			deliveries := make(chan deliveryJob, 1)
+			current := test.current
+			if !processResult(context.Background(), deliveries, currentConfig.Load(), info, &current) {
+				t.Fatal("processing stopped")
+			}
+
+			if delivered := len(deliveries) == 1; delivered != test.delivered {
+				t.Fatalf("delivered = %v, want %v", delivered, test.delivered)
+			}
+
+			entry, _, _ := subscriptions.GetStatus(key)
+			if !strings.HasPrefix(entry.Hash, statusHashVersion) {
+				t.Fatalf("stored hash %q was not upgraded", entry.Hash)
+			}
+		})
+	}
+}
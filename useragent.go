@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+var userAgent string
+var userAgents []string
+var userAgentIndex atomic.Uint64
+
+type userAgentTransport struct {
+	base http.RoundTripper
+}
+
+func defaultUserAgent() string {
+	return "steam-status/" + version + " (+https://github.com/TerrayTM/steam-status)"
+}
+
+func nextUserAgent() string {
+	if len(userAgents) == 0 {
+		return userAgent
+	}
+
+	return userAgents[(userAgentIndex.Add(1)-1)%uint64(len(userAgents))]
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", nextUserAgent())
+	return t.base.RoundTrip(req)
+}
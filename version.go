@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+)
+
+var version = "dev"
+var commit = "unknown"
+var buildDate = "unknown"
+
+func versionHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, struct {
+		Version   string    `json:"version"`
+		Commit    string    `json:"commit"`
+		BuildDate string    `json:"buildDate"`
+		GoVersion string    `json:"goVersion"`
+		StartTime time.Time `json:"startTime"`
+	}{
+		version,
+		commit,
+		buildDate,
+		runtime.Version(),
+		startTime,
+	})
+}
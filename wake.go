@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+)
+
+var wakeups = make(chan string, 64)
+
+func wakeIdentifier(raw json.RawMessage) string {
+	var identifier string
+	if json.Unmarshal(raw, &identifier) != nil {
+		return string(raw)
+	}
+
+	return identifier
+}
+
+func matchWake(identifier string) ([]string, error) {
+	queue, err := subscriptions.List()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := queue[identifier]; ok {
+		return []string{identifier}, nil
+	}
+
+	page := normalizePage(identifier)
+	if canonical, reason := canonicalPage(identifier); len(reason) == 0 {
+		page = canonical
+	}
+
+	keys := []string{}
+	for key, info := range queue {
+		if info.Page == page || info.Original == page {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+func wakeSubscriptions(identifier string) ([]string, int, error) {
+	keys, err := matchWake(identifier)
+	if err != nil {
+		slog.Error("Failed to list subscriptions", "err", err)
+		return nil, 0, err
+	}
+
+	recordWake()
+	scheduled := scheduleWake(keys)
+	slog.Info("Wake requested", "identifier", identifier, "matched", len(keys), "scheduled", scheduled)
+
+	return keys, scheduled, nil
+}
+
+func scheduleWake(keys []string) int {
+	scheduled := 0
+	for _, key := range keys {
+		select {
+		case wakeups <- key:
+		default:
+			pollNextCycle(key)
+		}
+		scheduled++
+	}
+
+	return scheduled
+}
+
+func runWakeup(ctx context.Context, source scraper, deliveries chan<- deliveryJob, key string) bool {
+	info, ok, err := subscriptions.Get(key)
+	if err != nil {
+		slog.Error("Failed to read woken subscription", "key", key, "err", err)
+		return true
+	}
+
+	if !ok || paused.Load() || steamDown() || !scrapeBreaker.allow() {
+		return true
+	}
+
+	settings := currentConfig.Load()
+	if !waitThrottle(ctx) || !scrapePacer.wait(ctx, jittered(settings.PollDelay.Duration, settings.Jitter)) {
+		return false
+	}
+
+	response := gatherStatus(ctx, source, info.Page)
+	scrapeBreaker.record(!retryableStatus(response.StatusCode))
+
+	return processResult(ctx, deliveries, settings, info, response)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRunWakeup(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func()
+		scraped int
+	}{
+		{"scrapes", func() {}, 1},
+		{"paused", func() { paused.Store(true) }, 0},
+		{"steam down", func() { lastProbe = probeResult{CheckedAt: time.Now()} }, 0},
+		{"breaker open", func() { scrapeBreaker.transition(breakerOpen) }, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setupTest(t)
+			cooldown := scrapeBreaker.cooldown
+			t.Cleanup(func() {
+				paused.Store(false)
+				lastProbe = probeResult{}
+				scrapeBreaker.transition(breakerClosed)
+				scrapeBreaker.cooldown = cooldown
+			})
+
+			scrapeBreaker.cooldown = time.Hour
+			test.setup()
+
+			key := addSubscription("https://steamcommunity.com/id/wake/")
+			source := &fakeScraper{responses: []*statusInfo{{StatusCode: 200, Visibility: "public"}}}
+
+			if !runWakeup(context.Background(), source, make(chan deliveryJob, 1), key) {
+				t.Fatal("wakeup stopped")
+			}
+
+			if len(source.scraped()) != test.scraped {
+				t.Fatalf("scraped %d times, want %d", len(source.scraped()), test.scraped)
+			}
+		})
+	}
+}
+
+func TestScheduleWakeFallsBackToNextCycle(t *testing.T) {
+	setupTest(t)
+	clock := useFakeClock(t)
+	t.Cleanup(func() {
+		for len(wakeups) != 0 {
+			<-wakeups
+		}
+	})
+
+	for len(wakeups) < cap(wakeups) {
+		wakeups <- "filler"
+	}
+
+	info := requestInfo{Page: "https://steamcommunity.com/id/full/", IntervalSeconds: 600}
+	key := subscriptionID(&info)
+	subscriptionStates[key] = subscriptionState{NextPollAt: clock.Now().Add(time.Hour), BackoffUntil: clock.Now().Add(time.Hour)}
+
+	if scheduled := scheduleWake([]string{key}); scheduled != 1 {
+		t.Fatalf("scheduled %d wakeups", scheduled)
+	}
+
+	if !pollDue(key, &info, clock.Now()) {
+		t.Fatal("subscription should be polled on the next cycle")
+	}
+}
+
+type blockingScraper struct {
+	fakeScraper
+	page    string
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingScraper) Scrape(ctx context.Context, page string) (*statusInfo, error) {
+	if page == b.page {
+		close(b.started)
+		select {
+		case <-b.release:
+		case <-ctx.Done():
+		}
+	}
+
+	return b.fakeScraper.Scrape(ctx, page)
+}
+
+func TestWakeServedDuringSlowCycle(t *testing.T) {
+	setupTest(t)
+	t.Cleanup(func() {
+		for len(wakeups) != 0 {
+			<-wakeups
+		}
+	})
+
+	slow, _, _ := subscriptions.Get(addSubscription("https://steamcommunity.com/id/slow/"))
+	source := &blockingScraper{
+		fakeScraper: fakeScraper{responses: []*statusInfo{{StatusCode: 200, Visibility: "public"}}},
+		page:        slow.Page,
+		started:     make(chan struct{}),
+		release:     make(chan struct{}),
+	}
+
+	done := make(chan bool)
+	go func() {
+		_, ok := runCycle(context.Background(), source, make(chan deliveryJob, 4))
+		done <- ok
+	}()
+
+	<-source.started
+	key := addSubscription("https://steamcommunity.com/id/woken/")
+	woken, _, _ := subscriptions.Get(key)
+	scheduleWake([]string{key})
+
+	waitFor(t, func() bool {
+		for _, page := range source.scraped() {
+			if page == woken.Page {
+				return true
+			}
+		}
+		return false
+	})
+
+	select {
+	case <-done:
+		t.Fatal("cycle finished before the slow scrape was released")
+	default:
+	}
+
+	close(source.release)
+	if !<-done {
+		t.Fatal("cycle stopped")
+	}
+}
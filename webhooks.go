@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+type discordEmbed struct {
+	Title     string            `json:"title"`
+	URL       string            `json:"url,omitempty"`
+	Thumbnail *discordThumbnail `json:"thumbnail,omitempty"`
+	Timestamp string            `json:"timestamp"`
+}
+
+type discordThumbnail struct {
+	URL string `json:"url"`
+}
+
+type discordMentions struct {
+	Parse []string `json:"parse"`
+}
+
+type discordPayload struct {
+	Content         string          `json:"content"`
+	Embeds          []discordEmbed  `json:"embeds,omitempty"`
+	AllowedMentions discordMentions `json:"allowed_mentions"`
+}
+
+type discordRateLimit struct {
+	RetryAfter float64 `json:"retry_after"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackAccessory struct {
+	Type     string `json:"type"`
+	ImageURL string `json:"image_url"`
+	AltText  string `json:"alt_text"`
+}
+
+type slackBlock struct {
+	Type      string          `json:"type"`
+	Text      *slackText      `json:"text,omitempty"`
+	Accessory *slackAccessory `json:"accessory,omitempty"`
+}
+
+type slackPayload struct {
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+func webhookFormat(format string) bool {
+	return format == "discord" || format == "slack"
+}
+
+func statusSummary(info *requestInfo, response *statusInfo) string {
+	name := response.PersonaName
+	if len(name) == 0 {
+		name = info.callbackPage()
+	}
+
+	if response.IsPlaying && len(response.GameName) != 0 {
+		return name + " started playing " + response.GameName
+	}
+
+	if len(response.OnlineState) != 0 {
+		return name + " is now " + response.OnlineState
+	}
+
+	return name + " is not playing anything"
+}
+
+func callbackDiscord(info *requestInfo, response *statusInfo) string {
+	payload := discordPayload{Content: statusSummary(info, response), AllowedMentions: discordMentions{Parse: []string{}}}
+
+	if response.IsPlaying && len(response.GameName) != 0 {
+		embed := discordEmbed{
+			Title:     response.GameName,
+			URL:       response.GameLink,
+			Timestamp: response.ObservedAt.Format(time.RFC3339),
+		}
+		if len(response.GameIcon) != 0 {
+			embed.Thumbnail = &discordThumbnail{URL: response.GameIcon}
+		}
+		payload.Embeds = []discordEmbed{embed}
+	}
+
+	data, _ := json.Marshal(payload)
+	return string(data)
+}
+
+func slackEscape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+func callbackSlack(info *requestInfo, response *statusInfo) string {
+	summary := statusSummary(info, response)
+	section := slackBlock{
+		Type: "section",
+		Text: &slackText{Type: "mrkdwn", Text: slackEscape(summary) + "\n<" + info.callbackPage() + "|View profile>"},
+	}
+
+	if response.IsPlaying && len(response.GameIcon) != 0 {
+		section.Accessory = &slackAccessory{Type: "image", ImageURL: response.GameIcon, AltText: response.GameName}
+	}
+
+	data, _ := json.Marshal(slackPayload{Text: summary, Blocks: []slackBlock{section}})
+	return string(data)
+}
+
+func webhookExpired(info *requestInfo) string {
+	message := "Stopped watching " + info.callbackPage() + " because the subscription expired"
+
+	if info.Format == "slack" {
+		data, _ := json.Marshal(slackPayload{Text: message, Blocks: []slackBlock{{Type: "section", Text: &slackText{Type: "mrkdwn", Text: slackEscape(message)}}}})
+		return string(data)
+	}
+
+	data, _ := json.Marshal(discordPayload{Content: message, AllowedMentions: discordMentions{Parse: []string{}}})
+	return string(data)
+}
+
+func discordRetryAfter(body []byte) time.Duration {
+	limit := discordRateLimit{}
+	if err := json.Unmarshal(body, &limit); err != nil || limit.RetryAfter <= 0 {
+		return 0
+	}
+
+	return time.Duration(limit.RetryAfter * float64(time.Second))
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+type socketMessage struct {
+	Type string
+	Page string
+}
+
+type socketError struct {
+	Type  string    `json:"type"`
+	Page  string    `json:"page"`
+	Error *apiError `json:"error"`
+}
+
+const maxSocketPages = 32
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: socketOriginAllowed,
+}
+
+func socketOriginAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if len(origin) == 0 || len(allowedOrigin(origin)) != 0 {
+		return true
+	}
+
+	parsed, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(parsed.Host, r.Host)
+}
+
+func socketHandler(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	messages := make(chan interface{}, 8)
+	done := make(chan struct{})
+	subscriptions := make(map[string]chan hubEvent)
+
+	defer func() {
+		close(done)
+		for page, listener := range subscriptions {
+			hub.unsubscribe(page, listener)
+		}
+	}()
+
+	go func() {
+		for {
+			select {
+			case message := <-messages:
+				if conn.WriteJSON(message) != nil {
+					conn.Close()
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		var message socketMessage
+		if err := conn.ReadJSON(&message); err != nil {
+			return
+		}
+
+		if message.Type != "subscribe" || len(message.Page) == 0 {
+			continue
+		}
+
+		key, _, apiErr := streamSubscription(message.Page)
+		if apiErr == nil && len(subscriptions) >= maxSocketPages {
+			apiErr = newAPIError(codeInvalidField, "at most "+strconv.Itoa(maxSocketPages)+" pages can be watched per connection")
+		}
+
+		if apiErr != nil {
+			select {
+			case messages <- socketError{"error", message.Page, apiErr}:
+			default:
+			}
+			continue
+		}
+
+		if _, ok := subscriptions[key]; ok {
+			continue
+		}
+
+		listener := hub.subscribe(key)
+		subscriptions[key] = listener
+
+		go func(listener chan hubEvent) {
+			for {
+				select {
+				case event := <-listener:
+					select {
+					case messages <- event.Message:
+					case <-done:
+						return
+					}
+				case <-done:
+					return
+				}
+			}
+		}(listener)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type profileXML struct {
+	SteamID      string `xml:"steamID"`
+	AvatarFull   string `xml:"avatarFull"`
+	PrivacyState string `xml:"privacyState"`
+	InGameInfo   struct {
+		GameName string `xml:"gameName"`
+		GameLink string `xml:"gameLink"`
+		GameIcon string `xml:"gameIcon"`
+	} `xml:"inGameInfo"`
+}
+
+func (p *profileXML) visibility() string {
+	switch strings.ToLower(p.PrivacyState) {
+	case "private":
+		return "private"
+	case "friendsonly":
+		return "friendsOnly"
+	}
+
+	return "public"
+}
+
+func xmlURL(page string) string {
+	parsed, err := url.Parse(englishURL(page))
+	if err != nil {
+		return page
+	}
+
+	query := parsed.Query()
+	query.Set("xml", "1")
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+func fetchProfileXML(ctx context.Context, client *http.Client, page string) (*profileXML, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", xmlURL(page), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != 200 {
+		return nil, errors.New("profile xml returned " + response.Status)
+	}
+
+	profile := &profileXML{}
+	if err := xml.NewDecoder(io.LimitReader(response.Body, int64(scrapeMaxBytes))).Decode(profile); err != nil {
+		return nil, err
+	}
+
+	return profile, nil
+}